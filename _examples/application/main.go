@@ -21,5 +21,9 @@ func main() {
 
 	defer sloop.Cleanup()
 
-	sloop.Setup().Watch()
+	if err := sloop.Setup(); err != nil {
+		panic(err)
+	}
+
+	sloop.Watch()
 }
@@ -19,4 +19,11 @@ func main() {
 	// DAWN_FROM_ENV=hello go run ./examples/config
 	// output: hello
 	log.Println(config.GetString("from.env"))
+
+	// DB_PASS=secret go run ./examples/config
+	// output: root:secret@tcp(127.0.0.1:3306)/dawn
+	log.Println(config.GetString("dsn"))
+
+	// output: map[dsn:****** foo:bar]
+	log.Println(config.MaskedSettings())
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/go-dawn/dawn"
 	"github.com/go-dawn/dawn/config"
 	"github.com/go-dawn/dawn/daemon"
@@ -12,11 +14,16 @@ import (
 func main() {
 	// 🌶️ Notice that go run won't work in daemon mode
 	// 🌶️ Please at dawn root dir and run go build -o play ./_examples/daemon
-	// 🌶️ And run ./play
-	daemon.Run()
-
+	// 🌶️ And run ./play start, ./play status, ./play restart or ./play stop
 	config.Load("./_examples/daemon")
 
+	if exit, err := daemon.Command(os.Args[1:]); err != nil {
+		log.Errorln(err)
+		os.Exit(1)
+	} else if exit {
+		return
+	}
+
 	sloop := dawn.Default().
 		AddModulers(log.New(nil))
 
@@ -25,5 +32,13 @@ func main() {
 		return fiberx.Message(c, "I'm running in daemon 🍀")
 	})
 
-	log.Infoln(0, sloop.Run(":3000"))
+	// 🌶️ Listen inherits the listener of master, so ./play upgrade or
+	// 🌶️ kill -USR2 <master pid> upgrades the worker without downtime
+	ln, err := daemon.Listen(":3000")
+	if err != nil {
+		log.Errorln(err)
+		os.Exit(1)
+	}
+
+	log.Infoln(0, sloop.RunListenerUntilSignal(ln))
 }
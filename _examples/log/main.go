@@ -17,4 +17,10 @@ func main() {
 	log.Infoln(1, "info 1")
 	// Won't log if set -v=1
 	log.Infoln(2, "info 2")
+
+	// Structured logging
+	log.SetEncoder(log.JSONEncoder{})
+	l := log.With("module", "example")
+	l.Info("structured", "user", 1)
+	l.Warn("structured", "err", "something wrong")
 }
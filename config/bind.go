@@ -0,0 +1,295 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+)
+
+// BindError lists every bad or missing key found by Bind
+type BindError struct {
+	// Key is the key passed to Bind
+	Key string
+	// Fields maps full dotted keys to their problems
+	Fields map[string]string
+}
+
+func (e *BindError) Error() string {
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b := new(strings.Builder)
+	b.WriteString("config: failed to bind")
+	if e.Key != "" {
+		b.WriteString(" " + e.Key)
+	}
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(k + " " + e.Fields[k])
+	}
+
+	return b.String()
+}
+
+// Bind fills the struct pointed by out with the config subtree of key,
+// or the whole config if key is empty. A field is matched with the key
+// of its name or mapstructure tag case-insensitively, and embedded
+// structs are squashed. The default tag is used if the key is missing,
+// and then the struct is validated by validate tags with fiberx.V, e.g.
+//  type HTTP struct {
+//  	Port    int           `default:"8080" validate:"min=1,max=65535"`
+//  	Host    string        `validate:"required"`
+//  	Timeout time.Duration `default:"5s"`
+//  }
+//  var h HTTP
+//  err := config.Bind("http", &h)
+// The returned *BindError lists every bad or missing key with its
+// full dotted path like http.port.
+func Bind(key string, out interface{}) error {
+	return std().Bind(key, out)
+}
+func (c *Config) Bind(key string, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: bind needs a non-nil struct pointer, got %T", out)
+	}
+
+	settings, err := c.allSettings()
+	if err != nil {
+		return err
+	}
+
+	key = strings.ToLower(key)
+
+	b := &binder{c: c, err: &BindError{Key: key, Fields: map[string]string{}}}
+	b.bindStruct(rv.Elem(), lookupPath(settings, key), key)
+
+	if len(b.err.Fields) == 0 {
+		b.validate(rv.Elem(), key, out)
+	}
+
+	if len(b.err.Fields) > 0 {
+		return b.err
+	}
+
+	return nil
+}
+
+// lookupPath returns the map of the dotted key in settings
+func lookupPath(settings map[string]interface{}, key string) map[string]interface{} {
+	if key == "" {
+		return settings
+	}
+
+	m := settings
+	for _, k := range strings.Split(key, ".") {
+		sub, ok := m[k].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		m = sub
+	}
+
+	return m
+}
+
+type binder struct {
+	c   *Config
+	err *BindError
+	// masked is the same as MaskedSettings of c, only got on failures
+	masked map[string]interface{}
+}
+
+func (b *binder) fail(path, msg string) {
+	b.err.Fields[path] = msg
+}
+
+// shown returns the value of the path masked as MaskedSettings,
+// so that secrets don't leak into errors
+func (b *binder) shown(path string) interface{} {
+	if b.masked == nil {
+		b.masked = b.c.MaskedSettings()
+	}
+
+	return settingsValue(b.masked, path)
+}
+
+// bindStruct fills fields of the struct v with values in m
+func (b *binder) bindStruct(v reflect.Value, m map[string]interface{}, path string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		name, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			b.bindStruct(fv, m, path)
+			continue
+		}
+
+		fieldPath := joinKey(path, name)
+		value, found := m[name]
+
+		if isNested(f.Type) {
+			if sub, ok := value.(map[string]interface{}); ok || !found {
+				b.bindStruct(fv, sub, fieldPath)
+				continue
+			}
+		}
+
+		if !found {
+			def, ok := f.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			if err := decode(def, fv); err != nil {
+				b.fail(fieldPath, fmt.Sprintf("has invalid default %q for %s", def, f.Type))
+			}
+			continue
+		}
+
+		if err := decode(value, fv); err != nil {
+			b.fail(fieldPath, fmt.Sprintf("has invalid value %v for %s", b.shown(fieldPath), f.Type))
+		}
+	}
+}
+
+// validate validates out and records failures with full dotted keys
+func (b *binder) validate(v reflect.Value, path string, out interface{}) {
+	err := fiberx.V.Struct(out)
+	if err == nil {
+		return
+	}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		b.fail(path, err.Error())
+		return
+	}
+
+	for _, fe := range errs {
+		// the namespace starts with the struct name
+		ns := fe.StructNamespace()
+		ns = ns[strings.IndexByte(ns, '.')+1:]
+
+		msg := "is required"
+		if fe.Tag() != "required" {
+			tag := fe.Tag()
+			if fe.Param() != "" {
+				tag += "=" + fe.Param()
+			}
+			msg = fmt.Sprintf("failed on the '%s' validation", tag)
+		}
+
+		b.fail(joinKey(path, namespaceKey(v.Type(), ns)), msg)
+	}
+}
+
+// namespaceKey converts a namespace of struct fields like
+// Server.Ports[0] to the dotted key like server.ports[0]
+func namespaceKey(t reflect.Type, ns string) string {
+	var keys []string
+
+	for _, seg := range strings.Split(ns, ".") {
+		name, index := seg, ""
+		if i := strings.IndexByte(seg, '['); i >= 0 {
+			name, index = seg[:i], seg[i:]
+		}
+
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		f, ok := t.FieldByName(name)
+		if t.Kind() != reflect.Struct || !ok {
+			keys = append(keys, strings.ToLower(seg))
+			continue
+		}
+
+		t = f.Type
+		// squashed fields have no key
+		if f.Anonymous {
+			continue
+		}
+
+		key, _ := fieldKey(f)
+		keys = append(keys, key+index)
+
+		if index != "" {
+			for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+	}
+
+	return strings.Join(keys, ".")
+}
+
+// fieldKey returns the lowercase key of the field, or false
+// if the field is ignored by a "-" tag
+func fieldKey(f reflect.StructField) (string, bool) {
+	name := f.Name
+	if tag := f.Tag.Get("mapstructure"); tag != "" {
+		if tag = strings.Split(tag, ",")[0]; tag == "-" {
+			return "", false
+		} else if tag != "" {
+			name = tag
+		}
+	}
+
+	return strings.ToLower(name), true
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isNested reports whether fields of the type are bound one by one
+func isNested(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+// decode decodes value into v in the same way as Unmarshal
+func decode(value interface{}, v reflect.Value) error {
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToTimeHookFunc(time.RFC3339),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           v.Addr().Interface(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.Decode(value)
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	bindServer struct {
+		Host    string        `validate:"required"`
+		Port    int           `default:"8080" validate:"min=1,max=65535"`
+		Timeout time.Duration `default:"5s"`
+		Tags    []string      `default:"a,b"`
+		Labels  map[string]string
+		TLS     bindTLS `mapstructure:"tls"`
+		Ignored string  `mapstructure:"-"`
+	}
+	bindTLS struct {
+		Enable bool
+		Cert   string `mapstructure:"cert_file" validate:"required_with=Enable"`
+	}
+	bindApp struct {
+		bindBase
+		Servers []bindServer `validate:"dive"`
+		Main    bindServer
+		Started time.Time
+	}
+	bindBase struct {
+		Name string `default:"dawn"`
+	}
+)
+
+func Test_Config_Bind(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		c := New()
+		c.Set("http.host", "localhost")
+		c.Set("http.timeout", "1m")
+		c.Set("http.labels", map[string]interface{}{"env": "dev"})
+		c.Set("http.tls.enable", true)
+		c.Set("http.tls.cert_file", "cert.pem")
+		c.Set("http.ignored", "x")
+
+		var s bindServer
+		require.Nil(t, c.Bind("HTTP", &s))
+
+		assert.Equal(t, bindServer{
+			Host:    "localhost",
+			Port:    8080,
+			Timeout: time.Minute,
+			Tags:    []string{"a", "b"},
+			Labels:  map[string]string{"env": "dev"},
+			TLS:     bindTLS{Enable: true, Cert: "cert.pem"},
+		}, s)
+	})
+
+	t.Run("global", func(t *testing.T) {
+		reset()
+		Set("host", "localhost")
+		Set("port", "80")
+
+		var s bindServer
+		require.Nil(t, Bind("", &s))
+		assert.Equal(t, 80, s.Port)
+	})
+
+	t.Run("sub", func(t *testing.T) {
+		c := New()
+		c.Set("a.http.host", "localhost")
+
+		var s bindServer
+		require.Nil(t, c.Sub("a").Bind("http", &s))
+		assert.Equal(t, "localhost", s.Host)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		c := New()
+		started := time.Now()
+		c.Set("app.started", started)
+		c.Set("app.main.host", "main")
+		c.Set("app.servers", []interface{}{
+			map[string]interface{}{"host": "s1", "port": 81},
+		})
+
+		var a bindApp
+		require.Nil(t, c.Bind("app", &a))
+		assert.Equal(t, "dawn", a.Name)
+		assert.Equal(t, "main", a.Main.Host)
+		assert.Equal(t, 8080, a.Main.Port)
+		assert.Equal(t, started, a.Started)
+		require.Len(t, a.Servers, 1)
+		assert.Equal(t, 81, a.Servers[0].Port)
+	})
+
+	t.Run("bad and missing keys", func(t *testing.T) {
+		c := New()
+		c.Set("http.port", "abc")
+		c.Set("http.timeout", "forever")
+		c.Set("http.tls", "on")
+
+		var s bindServer
+		err := c.Bind("http", &s)
+
+		var be *BindError
+		require.True(t, errors.As(err, &be))
+		assert.Equal(t, "http", be.Key)
+		assert.Len(t, be.Fields, 3)
+		assert.Contains(t, be.Fields["http.port"], "invalid value abc")
+		assert.Contains(t, be.Fields["http.timeout"], "invalid value forever")
+		assert.Contains(t, be.Fields["http.tls"], "invalid value on")
+	})
+
+	t.Run("secret values", func(t *testing.T) {
+		require.NoError(t, os.Setenv("DAWN_TEST_BIND_PORT", "secret"))
+		defer func() { _ = os.Unsetenv("DAWN_TEST_BIND_PORT") }()
+
+		c := New()
+		c.Set("http.port", "${env:DAWN_TEST_BIND_PORT}")
+		c.Set("http.password", "hunter2")
+
+		var s struct {
+			Port     int
+			Password int
+		}
+		err := c.Sub("http").Bind("", &s)
+
+		var be *BindError
+		require.True(t, errors.As(err, &be))
+		assert.Equal(t, map[string]string{
+			"port":     "has invalid value ****** for int",
+			"password": "has invalid value ****** for int",
+		}, be.Fields)
+		assert.NotContains(t, err.Error(), "secret")
+		assert.NotContains(t, err.Error(), "hunter2")
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		c := New()
+		c.Set("app.main.port", 0)
+		c.Set("app.main.tls.enable", true)
+		c.Set("app.servers", []interface{}{map[string]interface{}{"host": "s1", "port": 70000}})
+
+		var a bindApp
+		err := c.Bind("app", &a)
+
+		var be *BindError
+		require.True(t, errors.As(err, &be))
+		assert.Equal(t, map[string]string{
+			"app.main.host":          "is required",
+			"app.main.port":          "failed on the 'min=1' validation",
+			"app.main.tls.cert_file": "failed on the 'required_with=Enable' validation",
+			"app.servers[0].port":    "failed on the 'max=65535' validation",
+		}, be.Fields)
+		assert.Equal(t, "config: failed to bind app: app.main.host is required; "+
+			"app.main.port failed on the 'min=1' validation; "+
+			"app.main.tls.cert_file failed on the 'required_with=Enable' validation; "+
+			"app.servers[0].port failed on the 'max=65535' validation", err.Error())
+	})
+
+	t.Run("invalid default", func(t *testing.T) {
+		var s struct {
+			Port int `default:"abc"`
+		}
+		err := New().Bind("", &s)
+		require.NotNil(t, err)
+		assert.Equal(t, `config: failed to bind: port has invalid default "abc" for int`, err.Error())
+	})
+
+	t.Run("invalid out", func(t *testing.T) {
+		var s bindServer
+		assert.NotNil(t, New().Bind("", s))
+		assert.NotNil(t, New().Bind("", (*bindServer)(nil)))
+
+		var i int
+		assert.NotNil(t, New().Bind("", &i))
+	})
+}
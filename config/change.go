@@ -0,0 +1,238 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+)
+
+// subscription is a callback of changes under a key prefix
+type subscription struct {
+	prefix string
+	fn     func(old, new interface{})
+	// last is the value seen by the callback
+	last interface{}
+}
+
+// OnChange registers fn called with the old and new values of the key
+// prefix after config is changed by Reload, file changes, Load, LoadAll,
+// MergeConfigMap or Set, e.g.
+//  cancel := config.OnChange("sql.connections", func(old, new interface{}) {
+//  	// reconnect databases
+//  })
+// fn is only called if the value of the prefix actually changes, and an
+// empty prefix stands for all settings. Values are resolved as GetXxx.
+// Callbacks are called one by one in the order of changes, possibly in
+// the goroutine of another change. OnChange on a Sub subscribes the full
+// key in the config which it comes from. The returned function
+// unregisters the callback.
+func OnChange(keyPrefix string, fn func(old, new interface{})) (cancel func()) {
+	return std().OnChange(keyPrefix, fn)
+}
+func (c *Config) OnChange(keyPrefix string, fn func(old, new interface{})) (cancel func()) {
+	root := c.rootConfig()
+	prefix := joinKey(c.prefix, strings.ToLower(keyPrefix))
+
+	_, values := root.snapshot(map[int]string{0: prefix})
+
+	sub := &subscription{
+		prefix: prefix,
+		fn:     fn,
+		last:   values[0],
+	}
+
+	h := root.hooks
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if h.subs == nil {
+		h.subs = make(map[int]*subscription)
+	}
+
+	id := h.subID
+	h.subs[id] = sub
+	h.subID++
+
+	return func() {
+		h.mut.Lock()
+		defer h.mut.Unlock()
+
+		delete(h.subs, id)
+	}
+}
+
+// touch marks c as changed, so Sub views are rebuilt and snapshots
+// of changes are ordered, c.mut must be held
+func (c *Config) touch() {
+	atomic.AddUint64(&c.gen, 1)
+}
+
+// changeCall is a callback to be called with a change
+type changeCall struct {
+	fn       func(old, new interface{})
+	old, new interface{}
+}
+
+// changed calls callbacks of changed prefixes. A snapshot older than
+// the one applied, or of a global config replaced by Load, is dropped.
+func (c *Config) changed() {
+	h := c.hooks
+	h.mut.Lock()
+	prefixes := make(map[int]string, len(h.subs))
+	for id, sub := range h.subs {
+		prefixes[id] = sub.prefix
+	}
+	h.mut.Unlock()
+
+	if len(prefixes) == 0 {
+		return
+	}
+
+	gen, values := c.snapshot(prefixes)
+
+	h.mut.Lock()
+	if (h.owner != nil && h.owner != c) || gen < h.applied {
+		h.mut.Unlock()
+		return
+	}
+	h.owner, h.applied = c, gen
+
+	for i := 0; i < h.subID; i++ {
+		sub, ok := h.subs[i]
+		if !ok {
+			continue
+		}
+		value, ok := values[i]
+		if !ok || reflect.DeepEqual(sub.last, value) {
+			continue
+		}
+		h.queue = append(h.queue, changeCall{sub.fn, sub.last, value})
+		sub.last = value
+	}
+
+	h.deliver()
+}
+
+// snapshot returns values of the prefixes with the gen of c, only the
+// values are resolved instead of all settings
+func (c *Config) snapshot(prefixes map[int]string) (uint64, map[int]interface{}) {
+	c.mut.RLock()
+	gen := atomic.LoadUint64(&c.gen)
+	settings := c.v.AllSettings()
+	values := make(map[int]interface{}, len(prefixes))
+	for id, prefix := range prefixes {
+		values[id] = copyValue(settingsValue(settings, prefix))
+	}
+	c.mut.RUnlock()
+
+	for id, value := range values {
+		values[id] = c.resolve(prefixes[id], value)
+	}
+
+	return gen, values
+}
+
+// deliver calls queued callbacks in order outside the lock, and only
+// one goroutine delivers at a time, h.mut must be held and is released
+func (h *hookSet) deliver() {
+	if h.delivering {
+		h.mut.Unlock()
+		return
+	}
+
+	h.delivering = true
+	defer func() {
+		h.delivering = false
+		h.mut.Unlock()
+	}()
+
+	for len(h.queue) > 0 {
+		call := h.queue[0]
+		h.queue = h.queue[1:]
+
+		func() {
+			h.mut.Unlock()
+			defer h.mut.Lock()
+
+			call.fn(call.old, call.new)
+		}()
+	}
+}
+
+// rootConfig returns the config which c comes from, and follows
+// the global config replaced by Load
+func (c *Config) rootConfig() *Config {
+	root := c.root
+	if root == nil {
+		root = c
+	}
+
+	for {
+		root.mut.RLock()
+		next := root.replacedBy
+		root.mut.RUnlock()
+
+		if next == nil {
+			return root
+		}
+		root = next
+	}
+}
+
+// refresh rebuilds a Sub view from all settings of its root if the
+// root has changed since it's built, values set in the view are kept
+func (c *Config) refresh() {
+	if c.root == nil {
+		return
+	}
+
+	root := c.rootConfig()
+	gen := atomic.LoadUint64(&root.gen)
+
+	c.mut.RLock()
+	fresh := c.base == root && c.seen == gen
+	c.mut.RUnlock()
+
+	if fresh {
+		return
+	}
+
+	root.mut.RLock()
+	settings := root.v.AllSettings()
+	root.mut.RUnlock()
+
+	v := viper.New()
+	if m, ok := settingsValue(settings, c.prefix).(map[string]interface{}); ok {
+		_ = v.MergeConfigMap(m)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for k, value := range c.src.overrides {
+		v.Set(k, value)
+	}
+	c.v, c.base, c.seen = v, root, gen
+}
+
+// settingsValue returns the value of the dotted key in settings
+func settingsValue(settings map[string]interface{}, key string) interface{} {
+	if key == "" {
+		return settings
+	}
+
+	var value interface{} = settings
+	for _, k := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if value, ok = m[k]; !ok {
+			return nil
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type changeRecorder struct {
+	mu      sync.Mutex
+	changes [][2]interface{}
+}
+
+func (r *changeRecorder) record(old, new interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.changes = append(r.changes, [2]interface{}{old, new})
+}
+
+func (r *changeRecorder) get() [][2]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([][2]interface{}(nil), r.changes...)
+}
+
+func Test_Config_OnChange(t *testing.T) {
+	c := New()
+	c.Set("sql.default", "mysql")
+
+	var sql, port, all changeRecorder
+	cancel := c.OnChange("SQL", sql.record)
+	c.OnChange("http.port", port.record)
+	c.OnChange("", all.record)
+
+	t.Run("set", func(t *testing.T) {
+		c.Set("http.port", 8080)
+		assert.Len(t, sql.get(), 0)
+		assert.Equal(t, [][2]interface{}{{nil, 8080}}, port.get())
+		assert.Len(t, all.get(), 1)
+
+		// not changed
+		c.Set("http.port", 8080)
+		assert.Len(t, port.get(), 1)
+		assert.Len(t, all.get(), 1)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		c.MergeConfigMap(map[string]interface{}{"sql": map[string]interface{}{"connections": map[string]interface{}{"mysql": "dsn"}}})
+		require.Len(t, sql.get(), 1)
+		assert.Equal(t, map[string]interface{}{"default": "mysql"}, sql.get()[0][0])
+		assert.Equal(t, map[string]interface{}{
+			"default":     "mysql",
+			"connections": map[string]interface{}{"mysql": "dsn"},
+		}, sql.get()[0][1])
+		assert.Len(t, port.get(), 1)
+	})
+
+	t.Run("sub", func(t *testing.T) {
+		var driver changeRecorder
+		c.Sub("sql").OnChange("default", driver.record)
+
+		c.Set("sql.default", "pg")
+		assert.Equal(t, [][2]interface{}{{"mysql", "pg"}}, driver.get())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		n := len(sql.get())
+		cancel()
+		c.Set("sql.default", "sqlite")
+		assert.Len(t, sql.get(), n)
+	})
+}
+
+func Test_Config_OnChangeOrder(t *testing.T) {
+	c := New()
+	c.Set("n", 0)
+
+	var n changeRecorder
+	c.OnChange("n", n.record)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Set("n", i)
+		}(i)
+	}
+	wg.Wait()
+
+	// every change follows the former one and ends with the final value
+	changes := n.get()
+	require.NotEmpty(t, changes)
+	for i := 1; i < len(changes); i++ {
+		assert.Equal(t, changes[i-1][1], changes[i][0])
+	}
+	assert.Equal(t, c.Get("n"), changes[len(changes)-1][1])
+}
+
+func Test_Config_OnChangeResolve(t *testing.T) {
+	var calls int32
+	RegisterResolver("count", ResolverFunc(func(arg string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return arg, nil
+	}))
+	defer func() {
+		resolversMut.Lock()
+		delete(resolvers, "count")
+		resolversMut.Unlock()
+	}()
+
+	c := New()
+	c.Set("secret", "${count:s}")
+
+	var port changeRecorder
+	c.OnChange("http.port", port.record)
+
+	// only watched keys are resolved
+	c.Set("http.port", 80)
+	assert.Equal(t, [][2]interface{}{{nil, 80}}, port.get())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+func Test_Config_OnChangeNested(t *testing.T) {
+	c := New()
+
+	var a, b changeRecorder
+	c.OnChange("a", func(old, new interface{}) {
+		a.record(old, new)
+		// changes in callbacks are delivered after the current one
+		c.Set("b", new)
+	})
+	c.OnChange("b", b.record)
+
+	c.Set("a", 1)
+	c.Set("a", 2)
+
+	assert.Equal(t, [][2]interface{}{{nil, 1}, {1, 2}}, a.get())
+	assert.Equal(t, [][2]interface{}{{nil, 1}, {1, 2}}, b.get())
+}
+
+func Test_Config_OnChangeReload(t *testing.T) {
+	dir := profileDir(t)
+	file := filepath.Join(dir, "config.toml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("env = \"dev\"\nport = 80"), 0600))
+
+	require.NoError(t, LoadE(dir))
+	defer reset()
+
+	var env, port changeRecorder
+	OnChange("env", env.record)
+	OnChange("port", port.record)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("env = \"prod\"\nport = 80"), 0600))
+
+	assert.Eventually(t, func() bool { return len(env.get()) == 1 }, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, [][2]interface{}{{"dev", "prod"}}, env.get())
+	assert.Len(t, port.get(), 0)
+
+	// subscriptions are kept by Load
+	require.NoError(t, ioutil.WriteFile(file, []byte("env = \"test\"\nport = 80"), 0600))
+	require.NoError(t, LoadE(dir))
+	assert.Equal(t, "test", env.get()[len(env.get())-1][1])
+	assert.Len(t, port.get(), 0)
+}
+
+func Test_Config_LiveSub(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		c := New()
+		c.Set("sql.connections.mysql.host", "a")
+
+		sub := c.Sub("sql")
+		conns := sub.Sub("connections")
+		assert.Equal(t, "a", conns.GetString("mysql.host"))
+
+		c.Set("sql.connections.mysql.host", "b")
+		assert.Equal(t, "b", conns.GetString("mysql.host"))
+		assert.Equal(t, "b", sub.Get("connections.mysql.host"))
+		assert.True(t, sub.Has("connections.mysql.host"))
+
+		// values set in the view are kept
+		conns.Set("mysql.port", 3306)
+		c.Set("sql.connections.mysql.host", "c")
+		assert.Equal(t, "c", conns.GetString("mysql.host"))
+		assert.Equal(t, 3306, conns.GetInt("mysql.port"))
+		assert.False(t, c.Has("sql.connections.mysql.port"))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		c := New()
+		sub := c.Sub("http")
+		assert.Len(t, sub.AllSettings(), 0)
+
+		c.Set("http.port", 80)
+		assert.Equal(t, 80, sub.GetInt("port"))
+	})
+
+	t.Run("global", func(t *testing.T) {
+		reset()
+		Set("sql.default", "mysql")
+		sub := Sub("sql")
+
+		Load(profilePath)
+		defer reset()
+
+		assert.Equal(t, "", sub.GetString("default"))
+
+		Set("sql.default", "pg")
+		assert.Equal(t, "pg", sub.GetString("default"))
+	})
+}
+
+func Test_Config_SettingsValue(t *testing.T) {
+	settings := map[string]interface{}{"a": map[string]interface{}{"b": 1}}
+
+	assert.Equal(t, settings, settingsValue(settings, ""))
+	assert.Equal(t, 1, settingsValue(settings, "a.b"))
+	assert.Nil(t, settingsValue(settings, "a.c"))
+	assert.Nil(t, settingsValue(settings, "a.b.c"))
+}
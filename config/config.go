@@ -10,28 +10,94 @@ import (
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
 // Config is based on spf13/viper
-// and extends by supporting default value
+// and extends by supporting default value.
+// It's safe for concurrent use.
 type Config struct {
+	// gen counts changes, it's first to be 64-bit aligned
+	gen uint64
+
 	v   *viper.Viper
 	mut sync.RWMutex
+
+	// profile is merged over the config file on loading
+	profile *profileLayer
+
+	// root is the config which a Sub comes from with the key
+	// prefix, refs are resolved and sources are explained from it
+	root   *Config
+	prefix string
+	// base and seen are the root and its gen which a Sub
+	// view is built from
+	base *Config
+	seen uint64
+
+	// replacedBy is the config replacing the global one by Load
+	replacedBy *Config
+
+	src provenance
+
+	hooks *hookSet
+}
+
+// hookSet holds reload hooks and change subscriptions, it's
+// shared by global configs replaced by Load
+type hookSet struct {
+	mut    sync.Mutex
+	hooks  map[int]func()
+	hookID int
+	subs   map[int]*subscription
+	subID  int
+	// owner is the config whose changes are applied last with
+	// the gen, it's replaced by Load
+	owner   *Config
+	applied uint64
+	// queue holds callbacks to be delivered in order
+	queue      []changeCall
+	delivering bool
 }
 
 var (
-	global *Config
+	globalMut sync.RWMutex
+	global    *Config
 )
 
 func init() {
 	global = New()
 }
 
+// std returns the global config
+func std() *Config {
+	globalMut.RLock()
+	defer globalMut.RUnlock()
+
+	return global
+}
+
+func newConfig(v *viper.Viper) *Config {
+	return &Config{v: v, hooks: new(hookSet)}
+}
+
 // New returns a new Config instance. If a specified filePath(with or without
 // extension are both fine) is given, then read config from that file.
-func New(filePath ...string) (c *Config) {
-	c = &Config{v: viper.New()}
+// It panics if the file can't be read.
+func New(filePath ...string) *Config {
+	c, err := NewE(filePath...)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// NewE is the same as New but returns error instead of panicking.
+func NewE(filePath ...string) (c *Config, err error) {
+	c = newConfig(viper.New())
 
 	if len(filePath) == 0 {
 		return
@@ -42,16 +108,27 @@ func New(filePath ...string) (c *Config) {
 
 	c.v.SetConfigName(strings.TrimRight(name, ext))
 	c.v.AddConfigPath(dir)
-	if err := c.v.ReadInConfig(); err != nil {
-		panic(fmt.Errorf("config: failed to read in %s: %w", fp, err))
+	if err = c.v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("config: failed to read in %s: %w", fp, err)
 	}
 
+	c.addLayer(SourceFile, c.v.ConfigFileUsed(), c.v.AllSettings())
+
 	return
 }
 
 // Load config into global environment.
 // Default config name is "config".
+// Files of the active profile are merged if any, see Profile.
+// It panics if the config can't be read.
 func Load(configPath string, configName ...string) {
+	if err := LoadE(configPath, configName...); err != nil {
+		panic(err)
+	}
+}
+
+// LoadE is the same as Load but returns error instead of panicking.
+func LoadE(configPath string, configName ...string) error {
 	v := viper.New()
 
 	name := "config"
@@ -62,17 +139,192 @@ func Load(configPath string, configName ...string) {
 	v.SetConfigName(name)
 	v.AddConfigPath(configPath)
 	if err := v.ReadInConfig(); err != nil {
-		panic(fmt.Errorf("config: failed to read in %s: %w", name, err))
+		return fmt.Errorf("config: failed to read in %s: %w", name, err)
+	}
+
+	c := newConfig(v)
+	c.addLayer(SourceFile, v.ConfigFileUsed(), v.AllSettings())
+
+	var err error
+	if c.profile, err = newProfileLayer(configPath, name, Profile()); err != nil {
+		return err
+	}
+
+	if c.profile != nil {
+		if err = c.profile.merge(c); err != nil {
+			return err
+		}
 	}
 
-	v.WatchConfig()
+	// keep reload hooks, subscriptions and defaults registered before
+	old := std()
+	c.hooks = old.hooks
+	old.mut.RLock()
+	for key, value := range old.src.defaults {
+		c.v.SetDefault(key, value)
+		c.src.defaults = replaceFlat(c.src.defaults, key, value)
+	}
+	old.mut.RUnlock()
+
+	if err := c.watch(); err != nil {
+		return fmt.Errorf("config: failed to watch %s: %w", name, err)
+	}
+
+	globalMut.Lock()
+	// Sub views of the former one follow the new one
+	old = global
+	old.mut.Lock()
+	old.replacedBy = c
+	old.mut.Unlock()
+	global = c
+	// changes of the former one are dropped from now on
+	c.hooks.mut.Lock()
+	c.hooks.owner, c.hooks.applied = c, 0
+	c.hooks.mut.Unlock()
+	globalMut.Unlock()
+
+	c.changed()
+
+	return nil
+}
+
+// Reload re-reads the config file if there is one, merges files of the
+// profile and maps merged by LoadAll and MergeConfigMap again, and then
+// calls all hooks registered by OnReload.
+func Reload() error {
+	return std().Reload()
+}
+func (c *Config) Reload() error {
+	c.mut.Lock()
+	var err error
+	// config is replaced only if all layers are read successfully
+	if file := c.v.ConfigFileUsed(); file != "" {
+		var (
+			v      *viper.Viper
+			layers []layer
+		)
+		if v, layers, err = c.rebuild(file); err == nil {
+			c.v, c.src.layers = v, layers
+			c.touch()
+		}
+	}
+	c.mut.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("config: failed to reload: %w", err)
+	}
+
+	c.changed()
+
+	c.hooks.mut.Lock()
+	hooks := make([]func(), 0, len(c.hooks.hooks))
+	for i := 0; i < c.hooks.hookID; i++ {
+		if fn, ok := c.hooks.hooks[i]; ok {
+			hooks = append(hooks, fn)
+		}
+	}
+	c.hooks.mut.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+
+	return nil
+}
+
+// rebuild reads the config file into a new viper and merges the profile,
+// then maps merged by LoadAll and MergeConfigMap are merged again in
+// their original order. Env settings, defaults and values set by Set are
+// applied as well, c.mut must be held. Values of .env are kept in env
+// variables, so they survive reloading.
+func (c *Config) rebuild(file string) (*viper.Viper, []layer, error) {
+	n := newConfig(viper.New())
+	n.v.SetConfigFile(file)
+	if err := n.v.ReadInConfig(); err != nil {
+		return nil, nil, err
+	}
+	n.addLayer(SourceFile, file, n.v.AllSettings())
+
+	if c.profile != nil {
+		if err := c.profile.merge(n); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, l := range c.src.layers {
+		if l.kind != SourceDir && l.kind != SourceMerge {
+			continue
+		}
+		if err := n.mergeLayer(l.kind, l.name, unflatten(l.settings, "")); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.src.env {
+		n.v.AutomaticEnv()
+		if c.src.envPrefix != "" {
+			n.v.SetEnvPrefix(c.src.envPrefix)
+		}
+		n.v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	}
+	for key, value := range c.src.defaults {
+		n.v.SetDefault(key, value)
+	}
+	for key, value := range c.src.overrides {
+		n.v.Set(key, value)
+	}
+
+	return n.v, n.src.layers, nil
+}
+
+// OnReload registers a hook which is called after config is reloaded
+// by Reload or file changes. Hooks are called in registration order.
+// The returned function unregisters the hook.
+func OnReload(fn func()) (cancel func()) {
+	return std().OnReload(fn)
+}
+func (c *Config) OnReload(fn func()) (cancel func()) {
+	h := c.hooks
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if h.hooks == nil {
+		h.hooks = make(map[int]func())
+	}
+
+	id := h.hookID
+	h.hooks[id] = fn
+	h.hookID++
+
+	return func() {
+		h.mut.Lock()
+		defer h.mut.Unlock()
 
-	global = &Config{v: v}
+		delete(h.hooks, id)
+	}
 }
 
 // LoadAll loads all config contents in the dir path
 func LoadAll(configPath string) error {
+	c := std()
+	defer c.changed()
+
+	return loadAll(configPath, func(path string, m map[string]interface{}) error {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+
+		c.touch()
+		return c.mergeLayer(SourceDir, path, m)
+	})
+}
+
+// loadAll merges every config file in the dir path by merge
+// with keys of its relative path
+func loadAll(configPath string, merge func(path string, m map[string]interface{}) error) error {
 	return filepath.Walk(configPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if !info.IsDir() {
 			dir, filename := filepath.Split(path)
 			name := strings.TrimSuffix(filename, filepath.Ext(filename))
@@ -87,7 +339,7 @@ func LoadAll(configPath string) error {
 
 			rel, _ := filepath.Rel(configPath, path)
 
-			global.MergeConfigMap(configMap(getKeys(rel), v.AllSettings()))
+			return merge(path, configMap(getKeys(rel), v.AllSettings()))
 		}
 		return nil
 	})
@@ -122,241 +374,255 @@ func getKeys(path string) (keys []string) {
 
 // Get is shorthand for GetValue.
 func Get(key string, defaultValue ...interface{}) interface{} {
-	return global.Get(key, defaultValue...)
+	return std().Get(key, defaultValue...)
 }
 func (c *Config) Get(key string, defaultValue ...interface{}) interface{} {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
 	return c.GetValue(key, defaultValue...)
 }
 
 // GetValue gets value of the key or fallback to the default value.
 func GetValue(key string, defaultValue ...interface{}) interface{} {
-	return global.GetValue(key, defaultValue...)
+	return std().GetValue(key, defaultValue...)
 }
 func (c *Config) GetValue(key string, defaultValue ...interface{}) interface{} {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return insensitive(defaultValue[0])
 	}
 
-	return c.v.Get(key)
+	return value
 }
 
 // GetBool gets bool value of the key or fallback to the default value.
 func GetBool(key string, defaultValue ...bool) bool {
-	return global.GetBool(key, defaultValue...)
+	return std().GetBool(key, defaultValue...)
 }
 func (c *Config) GetBool(key string, defaultValue ...bool) bool {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetBool(key)
+	return cast.ToBool(value)
 }
 
 // GetFloat64 gets float64 value of the key or fallback to the default value.
 func GetFloat64(key string, defaultValue ...float64) float64 {
-	return global.GetFloat64(key, defaultValue...)
+	return std().GetFloat64(key, defaultValue...)
 }
 func (c *Config) GetFloat64(key string, defaultValue ...float64) float64 {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetFloat64(key)
+	return cast.ToFloat64(value)
 }
 
 // GetInt gets int value of the key or fallback to the default value.
 func GetInt(key string, defaultValue ...int) int {
-	return global.GetInt(key, defaultValue...)
+	return std().GetInt(key, defaultValue...)
 }
 func (c *Config) GetInt(key string, defaultValue ...int) int {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetInt(key)
+	return cast.ToInt(value)
 }
 
 // GetInt64 gets int64 value of the key or fallback to the default value.
 func GetInt64(key string, defaultValue ...int64) int64 {
-	return global.GetInt64(key, defaultValue...)
+	return std().GetInt64(key, defaultValue...)
 }
 func (c *Config) GetInt64(key string, defaultValue ...int64) int64 {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetInt64(key)
+	return cast.ToInt64(value)
 }
 
 // GetString gets string value of the key or fallback to the default value.
 func GetString(key string, defaultValue ...string) string {
-	return global.GetString(key, defaultValue...)
+	return std().GetString(key, defaultValue...)
 }
 func (c *Config) GetString(key string, defaultValue ...string) string {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetString(key)
+	return cast.ToString(value)
 }
 
 // GetStringMap gets map[string]interface{} value of the key or fallback to the default value.
 func GetStringMap(key string, defaultValue ...map[string]interface{}) map[string]interface{} {
-	return global.GetStringMap(key, defaultValue...)
+	return std().GetStringMap(key, defaultValue...)
 }
 func (c *Config) GetStringMap(key string, defaultValue ...map[string]interface{}) map[string]interface{} {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return insensitive(defaultValue[0]).(map[string]interface{})
 	}
 
-	return c.v.GetStringMap(key)
+	return cast.ToStringMap(value)
 }
 
 // GetStringMapString gets map[string]string value of the key or fallback to the default value.
 func GetStringMapString(key string, defaultValue ...map[string]string) map[string]string {
-	return global.GetStringMapString(key, defaultValue...)
+	return std().GetStringMapString(key, defaultValue...)
 }
 func (c *Config) GetStringMapString(key string, defaultValue ...map[string]string) map[string]string {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetStringMapString(key)
+	return cast.ToStringMapString(value)
 }
 
 // GetStringSlice gets string slice value of the key or fallback to the default value.
 func GetStringSlice(key string, defaultValue ...[]string) []string {
-	return global.GetStringSlice(key, defaultValue...)
+	return std().GetStringSlice(key, defaultValue...)
 }
 func (c *Config) GetStringSlice(key string, defaultValue ...[]string) []string {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetStringSlice(key)
+	return cast.ToStringSlice(value)
 }
 
 // GetTime gets time value of the key or fallback to the default value.
 func GetTime(key string, defaultValue ...time.Time) time.Time {
-	return global.GetTime(key, defaultValue...)
+	return std().GetTime(key, defaultValue...)
 }
 func (c *Config) GetTime(key string, defaultValue ...time.Time) time.Time {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetTime(key)
+	return cast.ToTime(value)
 }
 
 // GetDuration gets duration value of the key or fallback to the default value.
 func GetDuration(key string, defaultValue ...time.Duration) time.Duration {
-	return global.GetDuration(key, defaultValue...)
+	return std().GetDuration(key, defaultValue...)
 }
 func (c *Config) GetDuration(key string, defaultValue ...time.Duration) time.Duration {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	if len(defaultValue) > 0 {
-		c.v.SetDefault(key, defaultValue[0])
+	value, found := c.value(key)
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
 	}
 
-	return c.v.GetDuration(key)
+	return cast.ToDuration(value)
 }
 
 // AllSettings gets all settings in config.
 func AllSettings() map[string]interface{} {
-	return global.AllSettings()
+	return std().AllSettings()
 }
 func (c *Config) AllSettings() map[string]interface{} {
+	c.refresh()
+
 	c.mut.RLock()
-	defer c.mut.RUnlock()
+	settings := c.v.AllSettings()
+	c.mut.RUnlock()
 
-	return c.v.AllSettings()
+	return c.resolve("", settings).(map[string]interface{})
 }
 
 // Unmarshal unmarshals the config into a Struct. Make sure that the tags
 // on the fields of the structure are properly set.
 func Unmarshal(rawVal interface{}) error {
-	return global.Unmarshal(rawVal)
+	return std().Unmarshal(rawVal)
 }
 func (c *Config) Unmarshal(rawVal interface{}) error {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
+	settings, err := c.allSettings()
+	if err != nil {
+		return err
+	}
 
-	return c.v.Unmarshal(rawVal)
+	return unmarshal(settings, rawVal)
 }
 
 // UnmarshalKey takes a single key and unmarshals it into a Struct.
 func UnmarshalKey(key string, rawVal interface{}) error {
-	return global.UnmarshalKey(key, rawVal)
+	return std().UnmarshalKey(key, rawVal)
 }
 func (c *Config) UnmarshalKey(key string, rawVal interface{}) error {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
+	value, _, err := c.lookup(key)
+	if err != nil {
+		return err
+	}
 
-	return c.v.UnmarshalKey(key, rawVal)
+	return unmarshal(value, rawVal)
+}
+
+// insensitive returns a copy of maps in value with lowercase keys,
+// the same as a default value registered in viper
+func insensitive(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		return insensitive(cast.ToStringMap(v))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			m[strings.ToLower(k)] = insensitive(elem)
+		}
+		return m
+	}
+
+	return value
+}
+
+// unmarshal decodes input into rawVal in the same way as viper
+func unmarshal(input, rawVal interface{}) error {
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           rawVal,
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.Decode(input)
 }
 
 // MergeConfigMap merges the configuration from the map given with an existing config.
 // Note that the map given may be modified.
 func MergeConfigMap(cfg map[string]interface{}) {
-	global.MergeConfigMap(cfg)
+	std().MergeConfigMap(cfg)
 }
 func (c *Config) MergeConfigMap(cfg map[string]interface{}) {
 	c.mut.Lock()
-	defer c.mut.Unlock()
+	_ = c.mergeLayer(SourceMerge, "", cfg)
+	c.touch()
+	c.mut.Unlock()
 
-	_ = c.v.MergeConfigMap(cfg)
+	c.changed()
 }
 
 // Sub returns a new Config instance representing a sub tree of this instance.
-// Sub is case-insensitive for a key.
+// Sub is case-insensitive for a key. It's a live view which reflects later
+// changes of the config it comes from, including the global config replaced
+// by Load, and values set in the view itself are kept.
 func Sub(key string) *Config {
-	return global.Sub(key)
+	return std().Sub(key)
 }
 func (c *Config) Sub(key string) *Config {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
-	var newConf *Config
-	if v := c.v.Sub(key); v != nil {
-		newConf = &Config{v: v}
-	} else {
-		newConf = New()
-	}
+	newConf := newConfig(nil)
+	newConf.root, newConf.prefix = c.rootConfig(), joinKey(c.prefix, strings.ToLower(key))
+	newConf.refresh()
 
 	return newConf
 }
@@ -366,21 +632,53 @@ func (c *Config) Sub(key string) *Config {
 // Will be used instead of values obtained via
 // flags, config file, ENV, default, or key/value store.
 func Set(key string, value interface{}) {
-	global.Set(key, value)
+	std().Set(key, value)
 }
 func (c *Config) Set(key string, value interface{}) {
 	c.mut.Lock()
-	defer c.mut.Unlock()
-
 	c.v.Set(key, value)
+	c.setOverride(key, value)
+	c.touch()
+	c.mut.Unlock()
+
+	c.changed()
+}
+
+// SetDefaults registers default values of keys, which are used if the
+// keys are not set in any other sources, e.g.
+//  config.SetDefaults(map[string]interface{}{
+//  	"http.port": 8080,
+//  	"sql":       map[string]interface{}{"default": "sqlite"},
+//  })
+// Keys are case-insensitive and can be dotted. Defaults are kept by Load,
+// and defaults set on a Sub are registered in the config it comes from.
+// Unlike them, a default value passed to GetXxx is only used by that call.
+func SetDefaults(defaults map[string]interface{}) {
+	std().SetDefaults(defaults)
+}
+func (c *Config) SetDefaults(defaults map[string]interface{}) {
+	root := c.rootConfig()
+
+	root.mut.Lock()
+	for key, value := range defaults {
+		key = joinKey(c.prefix, strings.ToLower(key))
+		root.v.SetDefault(key, value)
+		root.src.defaults = replaceFlat(root.src.defaults, key, value)
+	}
+	root.touch()
+	root.mut.Unlock()
+
+	root.changed()
 }
 
 // Has checks to see if the key has been set in any of the data locations.
 // Has is case-insensitive for a key.
 func Has(key string) bool {
-	return global.Has(key)
+	return std().Has(key)
 }
 func (c *Config) Has(key string) bool {
+	c.refresh()
+
 	c.mut.RLock()
 	defer c.mut.RUnlock()
 
@@ -391,15 +689,17 @@ func (c *Config) Has(key string) bool {
 // Use prefix to avoid conflicts with other env variables
 // Same config key in env will override that in config file
 func LoadEnv(prefix ...string) {
-	global.LoadEnv(prefix...)
+	std().LoadEnv(prefix...)
 }
 func (c *Config) LoadEnv(prefix ...string) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
 	c.v.AutomaticEnv()
+	c.src.env = true
 	if len(prefix) > 0 && prefix[0] != "" {
 		c.v.SetEnvPrefix(prefix[0])
+		c.src.envPrefix = prefix[0]
 	}
 	replacer := strings.NewReplacer(".", "_")
 	c.v.SetEnvKeyReplacer(replacer)
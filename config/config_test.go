@@ -1,7 +1,10 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -48,6 +51,18 @@ func Test_Config_New(t *testing.T) {
 	})
 }
 
+func Test_Config_NewE(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewE("./")
+	assert.Nil(t, c)
+	assert.Error(t, err)
+
+	c, err = NewE("./testdata/all/app.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, "dev", c.GetString("env"))
+}
+
 func Test_Config_Load_Panic(t *testing.T) {
 	t.Parallel()
 
@@ -57,6 +72,12 @@ func Test_Config_Load_Panic(t *testing.T) {
 	})
 }
 
+func Test_Config_LoadE(t *testing.T) {
+	t.Parallel()
+
+	assert.Error(t, LoadE(configPath, "non config name"))
+}
+
 func Test_Config_Load(t *testing.T) {
 	reset()
 
@@ -170,22 +191,220 @@ func Test_Config_LoadAll(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		assert.Nil(t, LoadAll("./testdata/all"))
-		assert.True(t, global.Has("http"))
-		assert.True(t, global.Has("others.1"))
+		assert.True(t, std().Has("http"))
+		assert.True(t, std().Has("others.1"))
 	})
 
 	t.Run("env", func(t *testing.T) {
 		assert.Nil(t, LoadAll("./testdata/all"))
-		assert.Equal(t, false, global.GetBool("app.debug"))
+		assert.Equal(t, false, std().GetBool("app.debug"))
 
 		LoadEnv("DAWN")
 
 		require.NoError(t, os.Setenv("DAWN_APP_DEBUG", "true"))
 
-		assert.Equal(t, true, global.GetBool("app.debug"))
+		assert.Equal(t, true, std().GetBool("app.debug"))
 	})
 }
 
 func reset() {
+	globalMut.Lock()
 	global = New()
+	globalMut.Unlock()
+}
+
+func Test_Config_Reload(t *testing.T) {
+	reset()
+
+	var calls []int
+	cancel := OnReload(func() { calls = append(calls, 1) })
+	OnReload(func() { calls = append(calls, 2) })
+
+	// no config file
+	assert.NoError(t, Reload())
+	assert.Equal(t, []int{1, 2}, calls)
+
+	// hooks are kept after loading
+	require.NoError(t, LoadE(configPath, configName))
+	cancel()
+	assert.NoError(t, Reload())
+	assert.Equal(t, []int{1, 2, 2}, calls)
+	assert.Equal(t, value, GetString(key))
+
+	t.Run("merged layers", func(t *testing.T) {
+		defer reset()
+
+		dir, err := ioutil.TempDir("", "dawn")
+		require.NoError(t, err)
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		file := filepath.Join(dir, "config.toml")
+		require.NoError(t, ioutil.WriteFile(file, []byte("foo = \"bar\"\nmerge = \"file\""), 0600))
+
+		require.NoError(t, LoadE(dir))
+		require.NoError(t, LoadAll("./testdata/all"))
+		MergeConfigMap(map[string]interface{}{"merge": "cfg"})
+		Set("set", "value")
+
+		require.NoError(t, ioutil.WriteFile(file, []byte("foo = \"baz\"\nmerge = \"file\""), 0600))
+		require.NoError(t, Reload())
+
+		assert.Equal(t, "baz", GetString("foo"))
+		assert.Equal(t, 8888, GetInt("http.port"))
+		assert.True(t, Has("others.1"))
+		assert.Equal(t, "cfg", GetString("merge"))
+		assert.Equal(t, "value", GetString("set"))
+
+		e := Explain("http.port")
+		require.NotNil(t, e.Source)
+		assert.Equal(t, SourceDir, e.Source.Kind)
+	})
+
+	t.Run("broken profile", func(t *testing.T) {
+		defer reset()
+
+		dir, err := ioutil.TempDir("", "dawn")
+		require.NoError(t, err)
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		setProfileEnv(t, "prod")
+		profile := filepath.Join(dir, "config.prod.toml")
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte(`db = "dev"`), 0600))
+		require.NoError(t, ioutil.WriteFile(profile, []byte(`db = "prod"`), 0600))
+
+		require.NoError(t, LoadE(dir))
+		require.NoError(t, LoadAll("./testdata/all"))
+
+		require.NoError(t, ioutil.WriteFile(profile, []byte(`db = "prod`), 0600))
+		assert.Error(t, Reload())
+		assert.Equal(t, "prod", GetString("db"))
+		assert.Equal(t, 8888, GetInt("http.port"))
+
+		// layers are still there for a later reload
+		require.NoError(t, ioutil.WriteFile(profile, []byte(`db = "prod2"`), 0600))
+		require.NoError(t, Reload())
+		assert.Equal(t, "prod2", GetString("db"))
+		assert.Equal(t, 8888, GetInt("http.port"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := New()
+		c.v.SetConfigFile("./non.toml")
+		assert.Error(t, c.Reload())
+	})
+}
+
+func Test_Config_LocalDefault(t *testing.T) {
+	c := New()
+
+	assert.Equal(t, 10, c.GetInt("daemon.tries", 10))
+	// the default of another call isn't kept
+	assert.Equal(t, 3, c.GetInt("daemon.tries", 3))
+	assert.Equal(t, 0, c.GetInt("daemon.tries"))
+	assert.False(t, c.Has("daemon.tries"))
+	assert.Len(t, c.AllSettings(), 0)
+
+	// a value set to zero isn't replaced by the default
+	c.Set("daemon.tries", 0)
+	assert.Equal(t, 0, c.GetInt("daemon.tries", 10))
+
+	// keys of default maps are lowercase as values in config
+	defaults := map[string]interface{}{"A": map[interface{}]interface{}{"B": 1}}
+	assert.Equal(t, map[string]interface{}{"a": map[string]interface{}{"b": 1}}, c.GetValue("map", defaults))
+	assert.Equal(t, map[string]interface{}{"a": map[string]interface{}{"b": 1}}, c.GetStringMap("map", defaults))
+	assert.Contains(t, defaults, "A")
+}
+
+func Test_Config_SetDefaults(t *testing.T) {
+	reset()
+	defer reset()
+
+	SetDefaults(map[string]interface{}{
+		"Http.Port": 8080,
+		"sql":       map[string]interface{}{"default": "sqlite"},
+	})
+
+	assert.Equal(t, 8080, GetInt("http.port"))
+	assert.Equal(t, 8080, GetInt("http.port", 80))
+	assert.Equal(t, "sqlite", Sub("sql").GetString("default"))
+	assert.True(t, Has("http.port"))
+
+	Set("http.port", 9090)
+	assert.Equal(t, 9090, GetInt("http.port"))
+
+	t.Run("sub", func(t *testing.T) {
+		Sub("redis").SetDefaults(map[string]interface{}{"addr": "127.0.0.1:6379"})
+		assert.Equal(t, "127.0.0.1:6379", GetString("redis.addr"))
+	})
+
+	t.Run("kept by load", func(t *testing.T) {
+		Load(configPath, configName)
+		assert.Equal(t, 8080, GetInt("http.port"))
+		assert.Equal(t, "127.0.0.1:6379", GetString("redis.addr"))
+		assert.Equal(t, value, GetString(key))
+	})
+}
+
+func Test_Config_Concurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dawn")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	file := filepath.Join(dir, "config.toml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("[sql]\ndefault = \"mysql\"\n[sql.connections.mysql]\nport = 3306"), 0600))
+
+	c := New(file)
+	sub := c.Sub("sql")
+	cancel := c.OnChange("sql", func(old, new interface{}) {})
+	defer cancel()
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	run(func(int) { _ = c.GetInt("sql.connections.mysql.port", 1) })
+	run(func(int) { _ = c.GetStringMap("sql", map[string]interface{}{}) })
+	run(func(int) { _ = sub.GetString("default", "pg") })
+	run(func(int) { _ = sub.Sub("connections").AllSettings() })
+	run(func(int) { _ = c.AllSettings() })
+	run(func(int) { _ = c.Explain("sql.default") })
+	run(func(i int) { c.Set("sql.connections.mysql.port", i) })
+	run(func(i int) {
+		c.MergeConfigMap(map[string]interface{}{"sql": map[string]interface{}{"connections": map[string]interface{}{"pg": i}}})
+	})
+	run(func(i int) { c.SetDefaults(map[string]interface{}{"sql.timeout": i}) })
+	run(func(int) { assert.NoError(t, c.Reload()) })
+
+	wg.Wait()
+
+	assert.Equal(t, "mysql", sub.GetString("default"))
+}
+
+func Test_Config_ConcurrentLoad(t *testing.T) {
+	defer reset()
+
+	sub := Sub("sub")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			Load(configPath, configName)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		_ = GetString(key, "default")
+		_ = sub.GetBool("b")
+		_ = Has(key)
+	}
+
+	<-done
+	assert.Equal(t, value, GetString(key))
 }
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Dump renders the effective config merged from all sources in the
+// format of toml, yaml or json. Secrets are masked as MaskedSettings.
+func Dump(format string) ([]byte, error) {
+	return std().Dump(format)
+}
+func (c *Config) Dump(format string) ([]byte, error) {
+	settings := c.MaskedSettings()
+
+	switch strings.ToLower(format) {
+	case "toml":
+		b := new(bytes.Buffer)
+		if err := toml.NewEncoder(b).Encode(settings); err != nil {
+			return nil, fmt.Errorf("config: failed to dump toml: %w", err)
+		}
+		return b.Bytes(), nil
+	case "yaml", "yml":
+		return yaml.Marshal(settings)
+	case "json":
+		return json.MarshalIndent(settings, "", "  ")
+	}
+
+	return nil, fmt.Errorf("config: unsupported dump format %q", format)
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Config_Dump(t *testing.T) {
+	require.NoError(t, os.Setenv("DAWN_DUMP_PASS", "secret"))
+	defer func() { _ = os.Unsetenv("DAWN_DUMP_PASS") }()
+
+	reset()
+	Set("sql.connections.mysql.password", "${env:DAWN_DUMP_PASS}")
+	Set("sql.connections.mysql.port", 3306)
+	Set("sql.default", "${ref:sql.connections.mysql.port}")
+
+	for format, expected := range map[string]string{
+		"json": `{
+  "sql": {
+    "connections": {
+      "mysql": {
+        "password": "******",
+        "port": 3306
+      }
+    },
+    "default": 3306
+  }
+}`,
+		"yaml": `sql:
+  connections:
+    mysql:
+      password: '******'
+      port: 3306
+  default: 3306
+`,
+		"TOML": `[sql]
+  default = 3306
+  [sql.connections]
+    [sql.connections.mysql]
+      password = "******"
+      port = 3306
+`,
+	} {
+		b, err := Dump(format)
+		require.NoError(t, err, format)
+		assert.Equal(t, expected, string(b), format)
+	}
+
+	_, err := Dump("xml")
+	assert.Error(t, err)
+
+	Set("mixed", []interface{}{"a", 1})
+	_, err = Dump("toml")
+	assert.Error(t, err)
+}
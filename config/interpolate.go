@@ -0,0 +1,381 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cast"
+)
+
+// Resolver resolves the argument of a placeholder like ${scheme:arg}
+// into its value.
+type Resolver interface {
+	Resolve(arg string) (string, error)
+}
+
+// ResolverFunc is an adapter to use an ordinary function as a Resolver.
+type ResolverFunc func(arg string) (string, error)
+
+// Resolve calls f(arg).
+func (f ResolverFunc) Resolve(arg string) (string, error) {
+	return f(arg)
+}
+
+// maskedValue replaces secrets in MaskedSettings
+const maskedValue = "******"
+
+// maxRefDepth limits nested refs to find cycles
+const maxRefDepth = 16
+
+var (
+	resolversMut sync.RWMutex
+	resolvers    = map[string]Resolver{
+		"env":  ResolverFunc(resolveEnv),
+		"file": ResolverFunc(resolveFile),
+	}
+)
+
+// RegisterResolver registers the resolver of placeholders with the
+// scheme, e.g. values encrypted by AESResolver:
+//  r, err := config.NewAESResolver("/etc/app/config.key")
+//  config.RegisterResolver("aes", r)
+// Placeholders in string values are resolved when they are read:
+//  ${env:DB_PASS}          value of the env variable
+//  ${file:/run/secrets/db} content of the file without the ending newline
+//  ${ref:sql.default}      value of another key from the root of config
+//  ${aes:...}              value resolved by the registered resolver
+// A value being exactly one ref keeps the type of the referred value, and
+// $${ is kept as a literal ${. Values resolved by env, file and registered
+// resolvers are treated as secrets and masked by MaskedSettings.
+//
+// GetXxx and AllSettings keep a placeholder as it is if it fails to
+// resolve, while Lookup, Bind and Unmarshal return the error.
+// It panics if the scheme is ref or empty.
+func RegisterResolver(scheme string, r Resolver) {
+	if scheme == "" || scheme == "ref" {
+		panic(fmt.Sprintf("config: can't register resolver of scheme %q", scheme))
+	}
+
+	resolversMut.Lock()
+	defer resolversMut.Unlock()
+
+	resolvers[scheme] = r
+}
+
+func resolveEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env %s is not set", name)
+	}
+
+	return value, nil
+}
+
+func resolveFile(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// resolution resolves placeholders in values of a config
+type resolution struct {
+	root *Config
+	// refs is the chain of keys being referred
+	refs []string
+	// secret tells whether any secret is resolved
+	secret bool
+}
+
+func (c *Config) newResolution() *resolution {
+	return &resolution{root: c.rootConfig()}
+}
+
+// value gets the value of the key with placeholders resolved,
+// and whether the key is set
+func (c *Config) value(key string) (interface{}, bool) {
+	c.refresh()
+
+	c.mut.RLock()
+	found := c.v.IsSet(key)
+	// values in viper may be changed after unlocking
+	value := copyValue(c.v.Get(key))
+	c.mut.RUnlock()
+
+	return c.resolve(strings.ToLower(key), value), found
+}
+
+// resolve resolves placeholders in value, and placeholders
+// failing to resolve are kept
+func (c *Config) resolve(key string, value interface{}) interface{} {
+	v, _ := walk(key, value, func(key, s string) (interface{}, error) {
+		if resolved, err := c.newResolution().string(key, s); err == nil {
+			return resolved, nil
+		}
+		return s, nil
+	})
+
+	return v
+}
+
+// lookup is the same as get but returns the error of resolution
+// and whether the key is set
+func (c *Config) lookup(key string) (value interface{}, found bool, err error) {
+	c.refresh()
+
+	c.mut.RLock()
+	found = c.v.IsSet(key)
+	value = copyValue(c.v.Get(key))
+	c.mut.RUnlock()
+
+	if !found {
+		return
+	}
+
+	value, err = walk(strings.ToLower(key), value, c.newResolution().string)
+
+	return
+}
+
+// allSettings gets all settings with placeholders resolved
+func (c *Config) allSettings() (map[string]interface{}, error) {
+	c.refresh()
+
+	c.mut.RLock()
+	settings := c.v.AllSettings()
+	c.mut.RUnlock()
+
+	v, err := walk("", settings, c.newResolution().string)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(map[string]interface{}), nil
+}
+
+// MaskedSettings is the same as AllSettings but resolved secrets and
+// values of keys looking like secrets are replaced by ******, so it's
+// safe to be dumped, see isSecretKey.
+func MaskedSettings() map[string]interface{} {
+	return std().MaskedSettings()
+}
+func (c *Config) MaskedSettings() map[string]interface{} {
+	c.refresh()
+
+	c.mut.RLock()
+	settings := c.v.AllSettings()
+	c.mut.RUnlock()
+
+	return c.masked("", settings).(map[string]interface{})
+}
+
+// masked is the same as resolve but resolved secrets
+// and values of secret keys are masked
+func (c *Config) masked(key string, value interface{}) interface{} {
+	v, _ := walk(key, value, func(key, s string) (interface{}, error) {
+		r := c.newResolution()
+		resolved, err := r.string(key, s)
+		if err != nil {
+			return s, nil
+		}
+		if r.secret {
+			return maskedValue, nil
+		}
+		return resolved, nil
+	})
+
+	return maskSecretKeys(key, v)
+}
+
+// secretWords are parts of key names looking like secrets
+var secretWords = []string{"password", "passwd", "secret", "token", "credential", "dsn"}
+
+// isSecretKey reports whether the last part of the dotted key looks like
+// a secret, that is containing password, passwd, secret, token, credential
+// or dsn, or ending with key like apikey and private_key.
+func isSecretKey(key string) bool {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	if i := strings.IndexByte(key, '['); i >= 0 {
+		key = key[:i]
+	}
+	key = strings.ToLower(key)
+
+	for _, w := range secretWords {
+		if strings.Contains(key, w) {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(key, "key")
+}
+
+// maskSecretKeys masks values of secret keys in value including
+// maps and slices, key is the dotted key of value
+func maskSecretKeys(key string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	if isSecretKey(key) {
+		return maskedValue
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			m[k] = maskSecretKeys(joinKey(key, k), elem)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, elem := range v {
+			s[i] = maskSecretKeys(fmt.Sprintf("%s[%d]", key, i), elem)
+		}
+		return s
+	}
+
+	return value
+}
+
+// walk replaces every string in value by fn, key is the dotted key of value
+func walk(key string, value interface{}, fn func(key, s string) (interface{}, error)) (interface{}, error) {
+	var err error
+
+	switch v := value.(type) {
+	case string:
+		return fn(key, v)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			if m[k], err = walk(joinKey(key, k), elem, fn); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, elem := range v {
+			if s[i], err = walk(fmt.Sprintf("%s[%d]", key, i), elem, fn); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case []string:
+		s := make([]string, len(v))
+		for i, elem := range v {
+			resolved, err := fn(fmt.Sprintf("%s[%d]", key, i), elem)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = cast.ToString(resolved)
+		}
+		return s, nil
+	}
+
+	return value, nil
+}
+
+// string resolves all placeholders in s
+func (r *resolution) string(key, s string) (interface{}, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	b := new(strings.Builder)
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+
+		// $${ is a literal ${
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			b.WriteString(s)
+			break
+		}
+		j += i
+
+		value, err := r.placeholder(s[i+2 : j])
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: failed to resolve %s: %w", key, s[i:j+1], err)
+		}
+
+		// a single ref keeps the type of the referred value
+		if i == 0 && j == len(s)-1 && b.Len() == 0 && strings.HasPrefix(s, "${ref:") {
+			return value, nil
+		}
+
+		b.WriteString(s[:i])
+		b.WriteString(cast.ToString(value))
+		s = s[j+1:]
+	}
+
+	return b.String(), nil
+}
+
+// placeholder resolves a placeholder like scheme:arg
+func (r *resolution) placeholder(p string) (interface{}, error) {
+	i := strings.IndexByte(p, ':')
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid placeholder")
+	}
+	scheme, arg := p[:i], p[i+1:]
+
+	if scheme == "ref" {
+		return r.ref(strings.ToLower(arg))
+	}
+
+	resolversMut.RLock()
+	resolver, ok := resolvers[scheme]
+	resolversMut.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown scheme %s", scheme)
+	}
+
+	r.secret = true
+
+	return resolver.Resolve(arg)
+}
+
+// ref resolves the value of the key from the root config
+func (r *resolution) ref(key string) (interface{}, error) {
+	for _, k := range r.refs {
+		if k == key {
+			return nil, fmt.Errorf("cyclic ref %s", strings.Join(append(r.refs, key), " -> "))
+		}
+	}
+	if len(r.refs) >= maxRefDepth {
+		return nil, fmt.Errorf("too many nested refs")
+	}
+
+	r.root.mut.RLock()
+	found := r.root.v.IsSet(key)
+	value := r.root.v.Get(key)
+	r.root.mut.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("ref %s is not set", key)
+	}
+
+	r.refs = append(r.refs, key)
+	defer func() { r.refs = r.refs[:len(r.refs)-1] }()
+
+	return walk(key, value, r.string)
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Config_Interpolate(t *testing.T) {
+	require.NoError(t, os.Setenv("DAWN_TEST_DB_PASS", "secret"))
+	defer func() { _ = os.Unsetenv("DAWN_TEST_DB_PASS") }()
+
+	dir, err := ioutil.TempDir("", "dawn")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	file := filepath.Join(dir, "db")
+	require.NoError(t, ioutil.WriteFile(file, []byte("from-file\n"), 0600))
+
+	c := New()
+	c.Set("sql.connections.mysql.password", "${env:DAWN_TEST_DB_PASS}")
+	c.Set("sql.connections.mysql.dsn", "user:${file:"+file+"}@tcp(${ref:db.host}:${ref:db.port})")
+	c.Set("sql.connections.mysql.port", "${ref:db.port}")
+	c.Set("sql.connections.mysql.tags", []string{"${ref:db.host}", "b"})
+	c.Set("db.host", "localhost")
+	c.Set("db.port", 3306)
+	c.Set("db.alias", "${ref:DB.HOST}")
+	c.Set("escaped", "$${env:DAWN_TEST_DB_PASS}")
+	c.Set("unclosed", "${env:DAWN_TEST_DB_PASS")
+	c.Set("missing", "${env:DAWN_TEST_NON}")
+
+	t.Run("get", func(t *testing.T) {
+		assert.Equal(t, "secret", c.GetString("sql.connections.mysql.password"))
+		assert.Equal(t, "user:from-file@tcp(localhost:3306)", c.GetString("sql.connections.mysql.dsn"))
+		assert.Equal(t, 3306, c.Get("sql.connections.mysql.port"))
+		assert.Equal(t, 3306, c.GetInt("sql.connections.mysql.port"))
+		assert.Equal(t, []string{"localhost", "b"}, c.GetStringSlice("sql.connections.mysql.tags"))
+		assert.Equal(t, "localhost", c.GetString("db.alias"))
+		assert.Equal(t, "${env:DAWN_TEST_DB_PASS}", c.GetString("escaped"))
+		assert.Equal(t, "${env:DAWN_TEST_DB_PASS", c.GetString("unclosed"))
+		assert.Equal(t, "${env:DAWN_TEST_NON}", c.GetString("missing"))
+	})
+
+	t.Run("sub", func(t *testing.T) {
+		sub := c.Sub("sql").Sub("connections.mysql")
+		assert.Equal(t, "secret", sub.GetString("password"))
+		assert.Equal(t, "user:from-file@tcp(localhost:3306)", sub.GetString("dsn"))
+		assert.Equal(t, []string{"localhost", "b"}, sub.GetStringSlice("tags"))
+	})
+
+	t.Run("all settings", func(t *testing.T) {
+		settings := c.AllSettings()
+		mysql := settings["sql"].(map[string]interface{})["connections"].(map[string]interface{})["mysql"].(map[string]interface{})
+		assert.Equal(t, "secret", mysql["password"])
+		assert.Equal(t, "${env:DAWN_TEST_NON}", settings["missing"])
+	})
+
+	t.Run("masked settings", func(t *testing.T) {
+		settings := c.MaskedSettings()
+		mysql := settings["sql"].(map[string]interface{})["connections"].(map[string]interface{})["mysql"].(map[string]interface{})
+		assert.Equal(t, maskedValue, mysql["password"])
+		assert.Equal(t, maskedValue, mysql["dsn"])
+		assert.Equal(t, 3306, mysql["port"])
+		assert.Equal(t, "localhost", settings["db"].(map[string]interface{})["alias"])
+		assert.Equal(t, "${env:DAWN_TEST_NON}", settings["missing"])
+	})
+
+	t.Run("secret keys", func(t *testing.T) {
+		c := New()
+		c.Set("redis.connections.default.password", "plain")
+		c.Set("app.apiKey", 123456)
+		c.Set("app.token", []string{"a", "b"})
+		c.Set("credentials", map[string]interface{}{"user": "u", "pass": "p"})
+		c.Set("servers", []interface{}{map[string]interface{}{"host": "h", "secret": "s"}})
+		c.Set("app.keys", []string{"k"})
+		c.Set("app.keyfile", "/etc/app.key")
+
+		settings := c.MaskedSettings()
+		app := settings["app"].(map[string]interface{})
+		assert.Equal(t, maskedValue, settings["redis"].(map[string]interface{})["connections"].(map[string]interface{})["default"].(map[string]interface{})["password"])
+		assert.Equal(t, maskedValue, app["apikey"])
+		assert.Equal(t, maskedValue, app["token"])
+		assert.Equal(t, maskedValue, settings["credentials"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"host": "h", "secret": maskedValue}}, settings["servers"])
+		assert.Equal(t, []string{"k"}, app["keys"])
+		assert.Equal(t, "/etc/app.key", app["keyfile"])
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		var s string
+		found, err := c.Lookup("sql.connections.mysql.password", &s)
+		assert.True(t, found)
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", s)
+
+		_, err = c.Lookup("missing", &s)
+		require.Error(t, err)
+		assert.Equal(t, "config: missing: failed to resolve ${env:DAWN_TEST_NON}: env DAWN_TEST_NON is not set", err.Error())
+
+		var out struct{ Missing string }
+		assert.Error(t, c.Unmarshal(&out))
+		assert.Error(t, c.UnmarshalKey("missing", &s))
+		assert.Error(t, c.Bind("", &out))
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var mysql struct {
+			Password string
+			Port     int
+			Tags     []string
+		}
+		require.NoError(t, c.UnmarshalKey("sql.connections.mysql", &mysql))
+		assert.Equal(t, "secret", mysql.Password)
+		assert.Equal(t, 3306, mysql.Port)
+		assert.Equal(t, []string{"localhost", "b"}, mysql.Tags)
+	})
+}
+
+func Test_Config_InterpolateErrors(t *testing.T) {
+	c := New()
+	c.Set("cycle.a", "${ref:cycle.b}")
+	c.Set("cycle.b", "x${ref:cycle.a}")
+	c.Set("unknown", "${foo:bar}")
+	c.Set("invalid", "${bar}")
+	c.Set("ref", "${ref:non}")
+	c.Set("file", "${file:/non/exist}")
+
+	var s string
+	for key, msg := range map[string]string{
+		"cycle.a": "cyclic ref cycle.b -> cycle.a -> cycle.b",
+		"unknown": "unknown scheme foo",
+		"invalid": "invalid placeholder",
+		"ref":     "ref non is not set",
+		"file":    "/non/exist",
+	} {
+		_, err := c.Lookup(key, &s)
+		require.Error(t, err, key)
+		assert.Contains(t, err.Error(), msg, key)
+	}
+
+	c.Set("deep.0", "end")
+	for i := 1; i <= maxRefDepth+1; i++ {
+		c.Set("deep."+strings.Repeat("x", i), "${ref:deep."+strings.Repeat("x", i-1)+"}")
+	}
+	c.Set("deep.x", "${ref:deep.0}")
+	_, err := c.Lookup("deep."+strings.Repeat("x", maxRefDepth), &s)
+	assert.NoError(t, err)
+	_, err = c.Lookup("deep."+strings.Repeat("x", maxRefDepth+1), &s)
+	assert.Contains(t, err.Error(), "too many nested refs")
+}
+
+func Test_Config_RegisterResolver(t *testing.T) {
+	RegisterResolver("upper", ResolverFunc(func(arg string) (string, error) {
+		if arg == "" {
+			return "", errors.New("empty")
+		}
+		return strings.ToUpper(arg), nil
+	}))
+	defer func() {
+		resolversMut.Lock()
+		delete(resolvers, "upper")
+		resolversMut.Unlock()
+	}()
+
+	c := New()
+	c.Set("name", "${upper:dawn}")
+	c.Set("empty", "${upper:}")
+
+	assert.Equal(t, "DAWN", c.GetString("name"))
+	assert.Equal(t, maskedValue, c.MaskedSettings()["name"])
+	assert.Equal(t, "${upper:}", c.GetString("empty"))
+
+	assert.Panics(t, func() { RegisterResolver("ref", nil) })
+	assert.Panics(t, func() { RegisterResolver("", nil) })
+}
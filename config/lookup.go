@@ -0,0 +1,400 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size is a number of bytes which can be written like "10MB"
+type Size int64
+
+// Units of Size, in powers of 1024
+const (
+	B  Size = 1
+	KB      = B << 10
+	MB      = KB << 10
+	GB      = MB << 10
+	TB      = GB << 10
+)
+
+var sizeUnits = map[string]Size{
+	"": B, "b": B,
+	"k": KB, "kb": KB, "kib": KB,
+	"m": MB, "mb": MB, "mib": MB,
+	"g": GB, "gb": GB, "gib": GB,
+	"t": TB, "tb": TB, "tib": TB,
+}
+
+// ParseSize parses a size like "512", "10MB" or "1.5gb".
+// Units are case-insensitive and in powers of 1024.
+func ParseSize(s string) (Size, error) {
+	s = strings.TrimSpace(s)
+
+	i := len(s)
+	for i > 0 && (s[i-1] < '0' || s[i-1] > '9') {
+		i--
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok || i == 0 {
+		return 0, fmt.Errorf("config: invalid size %q", s)
+	}
+
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || n < 0 || n*float64(unit) > math.MaxInt64 {
+		return 0, fmt.Errorf("config: invalid size %q", s)
+	}
+
+	return Size(n * float64(unit)), nil
+}
+
+func (s Size) String() string {
+	for _, u := range []struct {
+		size Size
+		name string
+	}{{TB, "TB"}, {GB, "GB"}, {MB, "MB"}, {KB, "KB"}} {
+		if s >= u.size && s%u.size == 0 {
+			return strconv.FormatInt(int64(s/u.size), 10) + u.name
+		}
+	}
+
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+// Lookup converts the value of the key into the variable pointed by
+// out strictly. Unlike GetXxx, it reports whether the key is found
+// and fails if the value can't be converted without loss, e.g.
+//  var timeout time.Duration
+//  found, err := config.Lookup("http.timeout", &timeout)
+// Besides bool, numbers and string, out can point to time.Duration,
+// time.Time, Size like "10MB", url.URL, and slices, maps with string
+// keys and pointers of them. A string is split by comma for slices.
+func Lookup(key string, out interface{}) (found bool, err error) {
+	return std().Lookup(key, out)
+}
+func (c *Config) Lookup(key string, out interface{}) (found bool, err error) {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return false, fmt.Errorf("config: lookup needs a non-nil pointer, got %T", out)
+	}
+
+	value, found, err := c.lookup(key)
+	if !found || err != nil {
+		return
+	}
+
+	key = strings.ToLower(key)
+	if err = convert(key, value, rv.Elem()); err != nil {
+		var ce *convertError
+		if errors.As(err, &ce) && c.hasSecret(key, value) {
+			ce.secret = true
+		}
+	}
+
+	return true, err
+}
+
+// hasSecret reports whether the resolved value of the key has
+// anything masked by MaskedSettings
+func (c *Config) hasSecret(key string, value interface{}) bool {
+	c.mut.RLock()
+	raw := copyValue(c.v.Get(key))
+	c.mut.RUnlock()
+
+	return !reflect.DeepEqual(c.masked(key, raw), value)
+}
+
+// Must is the same as Lookup but panics if the key is missing or
+// the value can't be converted.
+func Must(key string, out interface{}) {
+	std().Must(key, out)
+}
+func (c *Config) Must(key string, out interface{}) {
+	found, err := c.Lookup(key, out)
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		panic(fmt.Errorf("config: %s is missing", key))
+	}
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	sizeType     = reflect.TypeOf(Size(0))
+	urlType      = reflect.TypeOf(url.URL{})
+)
+
+// convertError is an error of converting the value of the key,
+// the value and the parsing error are hidden if it has a secret
+type convertError struct {
+	key    string
+	value  interface{}
+	typ    reflect.Type
+	reason string
+	// parseErr may contain the value
+	parseErr error
+	secret   bool
+}
+
+func (e *convertError) Error() string {
+	value, reason := e.value, e.reason
+	if e.secret {
+		value = maskedValue
+	} else if e.parseErr != nil {
+		reason = e.parseErr.Error()
+	}
+
+	msg := fmt.Sprintf("config: %s: cannot convert %v (%T) to %s", e.key, value, e.value, e.typ)
+	if reason != "" {
+		msg += ": " + reason
+	}
+
+	return msg
+}
+
+// convert converts value into v, key is used in errors
+func convert(key string, value interface{}, v reflect.Value) error {
+	fail := func(reason ...string) error {
+		e := &convertError{key: key, value: value, typ: v.Type()}
+		if len(reason) > 0 {
+			e.reason = reason[0]
+		}
+		return e
+	}
+	parseFail := func(err error) error {
+		return &convertError{key: key, value: value, typ: v.Type(), parseErr: err}
+	}
+
+	rv := reflect.ValueOf(value)
+
+	switch v.Type() {
+	case durationType:
+		if s, ok := value.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return parseFail(err)
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		// numbers are nanoseconds
+		return convertInt(rv, v, fail)
+	case sizeType:
+		if s, ok := value.(string); ok {
+			size, err := ParseSize(s)
+			if err != nil {
+				return fail()
+			}
+			v.SetInt(int64(size))
+			return nil
+		}
+		return convertInt(rv, v, fail)
+	case timeType:
+		t, err := toTime(value)
+		if err != nil {
+			return fail()
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case urlType:
+		s, ok := value.(string)
+		if !ok {
+			return fail()
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return parseFail(err)
+		}
+		if u.Scheme == "" {
+			return fail("missing scheme")
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if value != nil && !rv.Type().AssignableTo(v.Type()) {
+			return fail()
+		}
+		if value != nil {
+			v.Set(rv)
+		}
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := convert(key, value, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Bool:
+		switch b := value.(type) {
+		case bool:
+			v.SetBool(b)
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return fail()
+			}
+			v.SetBool(parsed)
+		default:
+			return fail()
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return convertInt(rv, v, fail)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			f = rv.Float()
+		case reflect.String:
+			parsed, err := strconv.ParseFloat(rv.String(), 64)
+			if err != nil {
+				return fail()
+			}
+			f = parsed
+		default:
+			return fail()
+		}
+		if v.OverflowFloat(f) {
+			return fail("overflow")
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		switch rv.Kind() {
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			v.SetString(fmt.Sprint(value))
+		default:
+			return fail()
+		}
+	case reflect.Slice:
+		if s, ok := value.(string); ok {
+			var items []interface{}
+			if s = strings.TrimSpace(s); s != "" {
+				for _, item := range strings.Split(s, ",") {
+					items = append(items, strings.TrimSpace(item))
+				}
+			}
+			rv = reflect.ValueOf(items)
+		}
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fail()
+		}
+		slice := reflect.MakeSlice(v.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := convert(fmt.Sprintf("%s[%d]", key, i), rv.Index(i).Interface(), slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return fail()
+		}
+		m := reflect.MakeMapWithSize(v.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := convert(key+"."+k, iter.Value().Interface(), elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+	default:
+		return fail("unsupported type, use Bind for structs")
+	}
+
+	return nil
+}
+
+// convertInt converts integers, integral floats and decimal
+// strings into v without overflow
+func convertInt(rv reflect.Value, v reflect.Value, fail func(...string) error) error {
+	// the value is kept as sign and magnitude
+	var (
+		neg bool
+		mag uint64
+	)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		neg, mag = i < 0, uint64(i)
+		if neg {
+			mag = uint64(-i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		mag = rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || math.Abs(f) >= math.MaxUint64 {
+			return fail("not an integer")
+		}
+		neg, mag = f < 0, uint64(math.Abs(f))
+	case reflect.String:
+		s := rv.String()
+		neg = strings.HasPrefix(s, "-")
+		n, err := strconv.ParseUint(strings.TrimPrefix(s, "-"), 10, 64)
+		if err != nil {
+			return fail()
+		}
+		mag = n
+	default:
+		return fail()
+	}
+
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if (neg && mag != 0) || v.OverflowUint(mag) {
+			return fail("overflow")
+		}
+		v.SetUint(mag)
+	default:
+		if (!neg && mag > math.MaxInt64) || (neg && mag > 1<<63) {
+			return fail("overflow")
+		}
+		i := int64(mag)
+		if neg {
+			i = -i
+		}
+		if v.OverflowInt(i) {
+			return fail("overflow")
+		}
+		v.SetInt(i)
+	}
+
+	return nil
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+func toTime(value interface{}) (time.Time, error) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %v", value)
+}
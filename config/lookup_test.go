@@ -0,0 +1,255 @@
+package config
+
+import (
+	"math"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Config_ParseSize(t *testing.T) {
+	for s, expected := range map[string]Size{
+		"512":    512,
+		"1b":     1,
+		"10MB":   10 * MB,
+		"10 mb":  10 * MB,
+		"1.5GiB": GB + GB/2,
+		"2k":     2 * KB,
+		"1TB":    TB,
+		" 3 kb ": 3 * KB,
+		"0":      0,
+	} {
+		size, err := ParseSize(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, size, s)
+	}
+
+	for _, s := range []string{"", "MB", "10XB", "-1MB", "1.2.3MB", "99999999TB"} {
+		_, err := ParseSize(s)
+		assert.Error(t, err, s)
+	}
+
+	assert.Equal(t, "10MB", (10 * MB).String())
+	assert.Equal(t, "1536KB", (MB + MB/2).String())
+	assert.Equal(t, "100B", Size(100).String())
+	assert.Equal(t, "0B", Size(0).String())
+}
+
+func Test_Config_Lookup(t *testing.T) {
+	c := New()
+	c.Set("int", 8080)
+	c.Set("intString", "-42")
+	c.Set("float", 1.5)
+	c.Set("integralFloat", 1e9)
+	c.Set("bool", "true")
+	c.Set("string", "abc")
+	c.Set("duration", "5s")
+	c.Set("size", "10MB")
+	c.Set("time", "2020-03-07 12:31:19")
+	c.Set("url", "https://example.com/path?q=1")
+	c.Set("slice", []interface{}{"1", 2, int64(3)})
+	c.Set("csv", "a, b,c")
+	c.Set("map", map[string]interface{}{"a": "1s", "b": 2000000000})
+	c.Set("nested.port", 80)
+
+	t.Run("success", func(t *testing.T) {
+		var (
+			i     int
+			i8    int8
+			u     uint
+			f     float64
+			b     bool
+			s     string
+			d     time.Duration
+			size  Size
+			tm    time.Time
+			u1    url.URL
+			u2    *url.URL
+			ints  []int
+			strs  []string
+			m     map[string]time.Duration
+			iface interface{}
+			p     *int
+		)
+
+		lookup := func(key string, out interface{}) {
+			found, err := c.Lookup(key, out)
+			assert.True(t, found, key)
+			assert.NoError(t, err, key)
+		}
+
+		lookup("int", &i)
+		assert.Equal(t, 8080, i)
+		lookup("intString", &i8)
+		assert.Equal(t, int8(-42), i8)
+		lookup("int", &u)
+		assert.Equal(t, uint(8080), u)
+		lookup("integralFloat", &i)
+		assert.Equal(t, int(1e9), i)
+		lookup("float", &f)
+		assert.Equal(t, 1.5, f)
+		lookup("intString", &f)
+		assert.Equal(t, -42.0, f)
+		lookup("bool", &b)
+		assert.True(t, b)
+		lookup("string", &s)
+		assert.Equal(t, "abc", s)
+		lookup("int", &s)
+		assert.Equal(t, "8080", s)
+		lookup("duration", &d)
+		assert.Equal(t, time.Second*5, d)
+		lookup("integralFloat", &d)
+		assert.Equal(t, time.Second, d)
+		lookup("size", &size)
+		assert.Equal(t, 10*MB, size)
+		lookup("int", &size)
+		assert.Equal(t, Size(8080), size)
+		lookup("time", &tm)
+		assert.Equal(t, time.Date(2020, 3, 7, 12, 31, 19, 0, time.UTC), tm)
+		lookup("url", &u1)
+		assert.Equal(t, "example.com", u1.Host)
+		lookup("url", &u2)
+		assert.Equal(t, "q=1", u2.RawQuery)
+		lookup("slice", &ints)
+		assert.Equal(t, []int{1, 2, 3}, ints)
+		lookup("csv", &strs)
+		assert.Equal(t, []string{"a", "b", "c"}, strs)
+		lookup("map", &m)
+		assert.Equal(t, map[string]time.Duration{"a": time.Second, "b": time.Second * 2}, m)
+		lookup("nested.port", &p)
+		assert.Equal(t, 80, *p)
+		lookup("string", &iface)
+		assert.Equal(t, "abc", iface)
+	})
+
+	t.Run("sub", func(t *testing.T) {
+		var port int
+		found, err := c.Sub("nested").Lookup("port", &port)
+		assert.True(t, found)
+		assert.NoError(t, err)
+		assert.Equal(t, 80, port)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		i := 1
+		found, err := c.Lookup("non", &i)
+		assert.False(t, found)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, i)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		c.Set("negative", -1)
+		c.Set("big", uint64(math.MaxUint64))
+		c.Set("relative", "/path")
+
+		var (
+			i   int
+			i8  int8
+			u   uint
+			b   bool
+			f32 float32
+			s   string
+			d   time.Duration
+			sz  Size
+			tm  time.Time
+			ul  url.URL
+			sl  []int
+			m   map[string]int
+			st  struct{}
+			ch  chan int
+			ifc error
+		)
+
+		for _, tc := range []struct {
+			key string
+			out interface{}
+		}{
+			{"string", &i},
+			{"float", &i},
+			{"int", &i8},
+			{"negative", &u},
+			{"big", &i},
+			{"int", &b},
+			{"string", &b},
+			{"string", &f32},
+			{"map", &f32},
+			{"slice", &s},
+			{"string", &d},
+			{"string", &sz},
+			{"string", &tm},
+			{"int", &ul},
+			{"relative", &ul},
+			{"int", &sl},
+			{"slice", &m},
+			{"map", &m},
+			{"int", &st},
+			{"int", &ch},
+			{"int", &ifc},
+		} {
+			found, err := c.Lookup(tc.key, tc.out)
+			assert.True(t, found, tc.key)
+			assert.Error(t, err, "%s -> %T", tc.key, tc.out)
+		}
+
+		var big float32
+		c.Set("huge", math.MaxFloat64)
+		_, err := c.Lookup("huge", &big)
+		assert.Error(t, err)
+
+		_, err = c.Lookup("map", &m)
+		assert.Contains(t, err.Error(), "config: map.a: cannot convert 1s (string) to int")
+
+		_, err = c.Lookup("int", i)
+		assert.Error(t, err)
+	})
+
+	t.Run("secret", func(t *testing.T) {
+		require.NoError(t, os.Setenv("DAWN_TEST_LOOKUP_SECRET", "s3cr3t"))
+		defer func() { _ = os.Unsetenv("DAWN_TEST_LOOKUP_SECRET") }()
+
+		c := New()
+		c.Set("timeout", "${env:DAWN_TEST_LOOKUP_SECRET}")
+		c.Set("db.password", "hunter2")
+		c.Set("hosts", map[string]interface{}{"a": "${env:DAWN_TEST_LOOKUP_SECRET}"})
+
+		var d time.Duration
+		_, err := c.Lookup("timeout", &d)
+		assert.EqualError(t, err, "config: timeout: cannot convert ****** (string) to time.Duration")
+
+		var i int
+		_, err = c.Sub("db").Lookup("password", &i)
+		assert.EqualError(t, err, "config: password: cannot convert ****** (string) to int")
+
+		var m map[string]int
+		_, err = c.Lookup("hosts", &m)
+		assert.EqualError(t, err, "config: hosts.a: cannot convert ****** (string) to int")
+
+		assert.PanicsWithError(t, "config: timeout: cannot convert ****** (string) to time.Duration", func() {
+			c.Must("timeout", &d)
+		})
+	})
+}
+
+func Test_Config_Must(t *testing.T) {
+	reset()
+	Set("port", "8080")
+
+	var port int
+	Must("port", &port)
+	assert.Equal(t, 8080, port)
+
+	found, err := Lookup("port", &port)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	assert.Panics(t, func() { Must("non", &port) })
+	assert.Panics(t, func() {
+		var b bool
+		Must("port", &b)
+	})
+}
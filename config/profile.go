@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvProfile is the env variable selecting the active profile
+const EnvProfile = "DAWN_PROFILE"
+
+// Profile returns the active profile like dev, staging or prod,
+// which is set by the --profile flag or the DAWN_PROFILE env, and
+// the flag wins. An empty profile means no profile is active.
+//
+// With an active profile, Load reads config files in the order:
+//  1. <dir>/config.toml
+//  2. <dir>/config.<profile>.toml, if it exists
+//  3. <dir>/<profile>/ merged like LoadAll, if it exists
+// A later one overrides keys in former ones. Env variables enabled by
+// LoadEnv override all of them, and Set overrides everything.
+func Profile() string {
+	if p, ok := profileFlag(os.Args[1:]); ok {
+		return p
+	}
+
+	return os.Getenv(EnvProfile)
+}
+
+// profileFlag finds -profile or --profile with "=" or a separate value
+func profileFlag(args []string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == arg {
+			continue
+		}
+
+		if name == "profile" && i+1 < len(args) {
+			return args[i+1], true
+		}
+
+		if strings.HasPrefix(name, "profile=") {
+			return strings.TrimPrefix(name, "profile="), true
+		}
+	}
+
+	return "", false
+}
+
+// profileLayer is config files of a profile merged over the main one
+type profileLayer struct {
+	// file is the profile file like <dir>/config.<profile>.toml
+	file string
+	// dir is the overlay dir like <dir>/<profile>
+	dir string
+}
+
+// newProfileLayer returns nil if profile is empty
+func newProfileLayer(configPath, configName, profile string) (*profileLayer, error) {
+	if profile == "" {
+		return nil, nil
+	}
+
+	if profile != filepath.Base(profile) || profile == "." || profile == ".." {
+		return nil, fmt.Errorf("config: invalid profile %q", profile)
+	}
+
+	return &profileLayer{
+		file: filepath.Join(configPath, configName+"."+profile),
+		dir:  filepath.Join(configPath, profile),
+	}, nil
+}
+
+// merge merges the profile file and overlay dir into c if they exist,
+// c.mut must be held
+func (l *profileLayer) merge(c *Config) error {
+	pv := viper.New()
+	pv.SetConfigName(filepath.Base(l.file))
+	pv.AddConfigPath(filepath.Dir(l.file))
+	if err := pv.ReadInConfig(); err == nil {
+		if err = c.mergeLayer(SourceProfile, pv.ConfigFileUsed(), pv.AllSettings()); err != nil {
+			return fmt.Errorf("config: failed to merge %s: %w", pv.ConfigFileUsed(), err)
+		}
+	} else if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+		return fmt.Errorf("config: failed to read in %s: %w", l.file, err)
+	}
+
+	if fi, err := os.Stat(l.dir); err != nil || !fi.IsDir() {
+		return nil
+	}
+
+	return loadAll(l.dir, func(path string, m map[string]interface{}) error {
+		return c.mergeLayer(SourceProfile, path, m)
+	})
+}
+
+// usedFile returns the profile file if it exists
+func (l *profileLayer) usedFile() string {
+	for _, ext := range viper.SupportedExts {
+		if f := l.file + "." + ext; fileExists(f) {
+			return f
+		}
+	}
+
+	return ""
+}
+
+func fileExists(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && !fi.IsDir()
+}
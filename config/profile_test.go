@@ -0,0 +1,166 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const profilePath = "./testdata/profile"
+
+func Test_Config_Profile(t *testing.T) {
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"app"}
+
+	assert.Equal(t, "", Profile())
+
+	setProfileEnv(t, "staging")
+	assert.Equal(t, "staging", Profile())
+
+	// flag wins
+	os.Args = []string{"app", "--profile", "prod"}
+	assert.Equal(t, "prod", Profile())
+}
+
+func Test_Config_ProfileFlag(t *testing.T) {
+	for _, args := range [][]string{
+		{"-profile", "prod"},
+		{"--profile", "prod"},
+		{"-profile=prod"},
+		{"serve", "--profile=prod", "-v"},
+	} {
+		p, ok := profileFlag(args)
+		assert.True(t, ok, args)
+		assert.Equal(t, "prod", p, args)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"profile", "prod"},
+		{"--profile"},
+		{"--", "--profile=prod"},
+		{"--profiles=prod"},
+	} {
+		_, ok := profileFlag(args)
+		assert.False(t, ok, args)
+	}
+}
+
+func Test_Config_LoadProfile(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		setProfileEnv(t, "")
+		require.NoError(t, LoadE(profilePath))
+
+		assert.Equal(t, "dev", GetString("env"))
+		assert.Equal(t, "127.0.0.1", GetString("http.host"))
+	})
+
+	t.Run("prod", func(t *testing.T) {
+		setProfileEnv(t, "prod")
+		require.NoError(t, LoadE(profilePath))
+
+		assert.Equal(t, "prod", GetString("env"))
+		assert.False(t, GetBool("debug"))
+		assert.Equal(t, "0.0.0.0", GetString("http.host"))
+		assert.Equal(t, 8080, GetInt("http.port"))
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		setProfileEnv(t, "prod")
+		require.NoError(t, LoadE(profilePath))
+
+		LoadEnv("DAWN_TEST")
+		require.NoError(t, os.Setenv("DAWN_TEST_HTTP_HOST", "env"))
+		defer func() { _ = os.Unsetenv("DAWN_TEST_HTTP_HOST") }()
+		assert.Equal(t, "env", GetString("http.host"))
+
+		Set("http.host", "set")
+		assert.Equal(t, "set", GetString("http.host"))
+	})
+
+	t.Run("missing layers", func(t *testing.T) {
+		setProfileEnv(t, "staging")
+		require.NoError(t, LoadE(profilePath))
+
+		assert.Equal(t, "dev", GetString("env"))
+	})
+
+	t.Run("invalid profile", func(t *testing.T) {
+		setProfileEnv(t, "../prod")
+		assert.Error(t, LoadE(profilePath))
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		dir := profileDir(t)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config.prod.toml"), []byte("invalid"), 0600))
+
+		setProfileEnv(t, "prod")
+		assert.Error(t, LoadE(dir))
+	})
+
+	t.Run("invalid overlay", func(t *testing.T) {
+		dir := profileDir(t)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "prod"), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "prod", "http.toml"), []byte("invalid"), 0600))
+
+		setProfileEnv(t, "prod")
+		assert.Error(t, LoadE(dir))
+	})
+}
+
+func Test_Config_ReloadProfile(t *testing.T) {
+	dir := profileDir(t)
+	file := filepath.Join(dir, "config.prod.toml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`env = "prod"`), 0600))
+
+	setProfileEnv(t, "prod")
+	require.NoError(t, LoadE(dir))
+	defer reset()
+
+	reloaded := make(chan struct{}, 10)
+	cancel := OnReload(func() { reloaded <- struct{}{} })
+	defer cancel()
+
+	// the profile file is watched
+	require.NoError(t, ioutil.WriteFile(file, []byte(`env = "production"`), 0600))
+
+	select {
+	case <-reloaded:
+		assert.Equal(t, "production", GetString("env"))
+	case <-time.After(time.Second * 3):
+		assert.Fail(t, "should reload config")
+	}
+
+	// the profile is merged again after reloading
+	require.NoError(t, Reload())
+	assert.Equal(t, "production", GetString("env"))
+}
+
+// profileDir creates a config dir with config.toml
+func profileDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dawn")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte(`env = "dev"`), 0600))
+
+	return dir
+}
+
+func setProfileEnv(t *testing.T, profile string) {
+	old, ok := os.LookupEnv(EnvProfile)
+	require.NoError(t, os.Setenv(EnvProfile, profile))
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(EnvProfile, old)
+		} else {
+			_ = os.Unsetenv(EnvProfile)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// AESResolver resolves values encrypted by AES-GCM with a local key file.
+// The key file holds a hex-encoded key of 16, 24 or 32 bytes, which can
+// be made by GenerateKeyFile, and values are made by Encrypt:
+//  r, err := config.NewAESResolver("/etc/app/config.key")
+//  config.RegisterResolver("aes", r)
+//  // Password = "${aes:<output of r.Encrypt>}"
+type AESResolver struct {
+	aead cipher.AEAD
+}
+
+// NewAESResolver returns an AESResolver with the key in the key file.
+func NewAESResolver(keyFile string) (*AESResolver, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to read key file: %w", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("config: invalid key file %s: %w", keyFile, err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("config: invalid key file %s: %w", keyFile, err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESResolver{aead: aead}, nil
+}
+
+// GenerateKeyFile writes a random 32 bytes key into the file
+// which is only readable by the owner.
+func GenerateKeyFile(keyFile string) error {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600)
+}
+
+// Encrypt encrypts the plaintext into the base64 encoded nonce
+// and ciphertext, which is the argument of the placeholder.
+func (r *AESResolver) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(r.aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// Resolve decrypts the value made by Encrypt.
+func (r *AESResolver) Resolve(arg string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(arg)
+	if err != nil {
+		return "", err
+	}
+
+	if len(b) < r.aead.NonceSize() {
+		return "", fmt.Errorf("ciphertext is too short")
+	}
+
+	nonce, ciphertext := b[:r.aead.NonceSize()], b[r.aead.NonceSize():]
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
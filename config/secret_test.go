@@ -0,0 +1,77 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Config_AESResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dawn")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	keyFile := filepath.Join(dir, "config.key")
+	require.NoError(t, GenerateKeyFile(keyFile))
+
+	if fi, err := os.Stat(keyFile); assert.NoError(t, err) && os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	r, err := NewAESResolver(keyFile)
+	require.NoError(t, err)
+
+	encrypted, err := r.Encrypt("p@ss}word")
+	require.NoError(t, err)
+
+	plaintext, err := r.Resolve(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "p@ss}word", plaintext)
+
+	t.Run("placeholder", func(t *testing.T) {
+		RegisterResolver("aes", r)
+		defer func() {
+			resolversMut.Lock()
+			delete(resolvers, "aes")
+			resolversMut.Unlock()
+		}()
+
+		c := New()
+		c.Set("redis.connections.default.password", "${aes:"+encrypted+"}")
+
+		assert.Equal(t, "p@ss}word", c.Sub("redis.connections.default").GetString("password"))
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		for _, arg := range []string{"!", "YQ==", encrypted[:len(encrypted)-4] + "AAA="} {
+			_, err := r.Resolve(arg)
+			assert.Error(t, err, arg)
+		}
+
+		// another key
+		other := filepath.Join(dir, "other.key")
+		require.NoError(t, GenerateKeyFile(other))
+		or, err := NewAESResolver(other)
+		require.NoError(t, err)
+		_, err = or.Resolve(encrypted)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid key file", func(t *testing.T) {
+		_, err := NewAESResolver(filepath.Join(dir, "non"))
+		assert.Error(t, err)
+
+		bad := filepath.Join(dir, "bad.key")
+		require.NoError(t, ioutil.WriteFile(bad, []byte("xyz"), 0600))
+		_, err = NewAESResolver(bad)
+		assert.Error(t, err)
+
+		require.NoError(t, ioutil.WriteFile(bad, []byte("abcd"), 0600))
+		_, err = NewAESResolver(bad)
+		assert.Error(t, err)
+	})
+}
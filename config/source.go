@@ -0,0 +1,328 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// SourceKind is the kind of place where a value comes from
+type SourceKind string
+
+// Kinds of sources in ascending order of precedence
+const (
+	// SourceDefault is a default value registered by SetDefaults
+	SourceDefault SourceKind = "default"
+	// SourceFile is the config file read by Load or New
+	SourceFile SourceKind = "file"
+	// SourceProfile is a file of the active profile
+	SourceProfile SourceKind = "profile"
+	// SourceDir is a file merged by LoadAll
+	SourceDir SourceKind = "dir"
+	// SourceMerge is a map merged by MergeConfigMap
+	SourceMerge SourceKind = "merge"
+	// SourceDotEnv is an env variable loaded from .env and enabled by LoadEnv
+	SourceDotEnv SourceKind = "dotenv"
+	// SourceEnv is an env variable enabled by LoadEnv
+	SourceEnv SourceKind = "env"
+	// SourceSet is a value set by Set
+	SourceSet SourceKind = "set"
+)
+
+// Source is a place where a value of the key comes from
+type Source struct {
+	Kind SourceKind
+	// Name is the file or env variable, empty for other kinds
+	Name string
+	// Value is the raw value before placeholders are resolved,
+	// values of keys looking like secrets are masked
+	Value interface{}
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return string(s.Kind)
+	}
+	return string(s.Kind) + " " + s.Name
+}
+
+// Explanation tells where the value of a key comes from
+type Explanation struct {
+	Key string
+	// Value is the effective value with secrets masked
+	Value interface{}
+	// Source is the winning source, nil if the key is not set
+	Source *Source
+	// Shadowed are sources overridden by the winning one,
+	// in descending order of precedence
+	Shadowed []Source
+}
+
+func (e Explanation) String() string {
+	if e.Source == nil {
+		return e.Key + ": not set"
+	}
+
+	b := new(strings.Builder)
+	_, _ = fmt.Fprintf(b, "%s: %v\n", e.Key, e.Value)
+	_, _ = fmt.Fprintf(b, "  %s: %v (winner)\n", e.Source, e.Source.Value)
+	for _, s := range e.Shadowed {
+		_, _ = fmt.Fprintf(b, "  %s: %v (shadowed)\n", s, s.Value)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Explain reports the winning source of the key and the shadowed ones,
+// e.g. config.Explain("http.port") may print as
+//  http.port: 9090
+//    env DAWN_HTTP_PORT: 9090 (winner)
+//    file config/config.toml: 8080 (shadowed)
+//    default: 80 (shadowed)
+// Sources are files read by Load, New, LoadAll and profiles, maps merged
+// by MergeConfigMap, env variables enabled by LoadEnv, which may come from
+// .env, values set by Set and defaults registered by SetDefaults. Default
+// values passed to GetXxx are local to the call, so they aren't sources.
+// Explain on a Sub explains the full key in the config which it comes from.
+func Explain(key string) Explanation {
+	return std().Explain(key)
+}
+func (c *Config) Explain(key string) Explanation {
+	key = strings.ToLower(key)
+
+	root, fullKey := c.rootConfig(), joinKey(c.prefix, key)
+
+	var sources []Source
+	if c != root {
+		// values set in the Sub itself
+		c.mut.RLock()
+		if v, ok := flatLookup(c.src.overrides, key); ok {
+			sources = append(sources, Source{Kind: SourceSet, Value: v})
+		}
+		c.mut.RUnlock()
+	}
+	sources = append(sources, root.sources(fullKey)...)
+
+	e := Explanation{Key: fullKey}
+	if len(sources) == 0 {
+		return e
+	}
+
+	e.Value = c.masked(fullKey, sources[0].Value)
+
+	// raw values of secret keys are masked as well
+	for i := range sources {
+		sources[i].Value = maskSecretKeys(fullKey, sources[i].Value)
+	}
+	e.Source, e.Shadowed = &sources[0], sources[1:]
+
+	return e
+}
+
+// sources finds all sources of the key in descending order of precedence
+func (c *Config) sources(key string) (sources []Source) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	if v, ok := flatLookup(c.src.overrides, key); ok {
+		sources = append(sources, Source{Kind: SourceSet, Value: v})
+	}
+
+	if c.src.env {
+		name := envName(c.src.envPrefix, key)
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			kind := SourceEnv
+			if dotenv, err := godotenv.Read(dotEnvFile); err == nil && dotenv[name] == v {
+				kind = SourceDotEnv
+			}
+			sources = append(sources, Source{Kind: kind, Name: name, Value: v})
+		}
+	}
+
+	for i := len(c.src.layers) - 1; i >= 0; i-- {
+		l := c.src.layers[i]
+		if v, ok := flatLookup(l.settings, key); ok {
+			sources = append(sources, Source{Kind: l.kind, Name: l.name, Value: v})
+		}
+	}
+
+	if v, ok := flatLookup(c.src.defaults, key); ok {
+		sources = append(sources, Source{Kind: SourceDefault, Value: v})
+	}
+
+	return
+}
+
+// dotEnvFile is loaded by godotenv autoload
+var dotEnvFile = ".env"
+
+// envName returns the env variable of the key in the same way as viper
+func envName(prefix, key string) string {
+	if prefix != "" {
+		key = prefix + "_" + key
+	}
+
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// provenance records where values of a config come from
+type provenance struct {
+	// layers are merged into the config layer in order
+	layers []layer
+	// overrides are flattened values set by Set
+	overrides map[string]interface{}
+	// env tells whether LoadEnv is called
+	env       bool
+	envPrefix string
+	// defaults are flattened values registered by SetDefaults
+	defaults map[string]interface{}
+}
+
+// layer is a file or map merged into the config layer
+type layer struct {
+	kind     SourceKind
+	name     string
+	settings map[string]interface{}
+}
+
+// addLayer records the settings of a source merged into the
+// config layer, c.mut must be held
+func (c *Config) addLayer(kind SourceKind, name string, settings map[string]interface{}) {
+	c.src.layers = append(c.src.layers, layer{kind: kind, name: name, settings: flatten("", settings, nil)})
+}
+
+// readLayer reads the file and records its settings, c.mut must be held
+func (c *Config) readLayer(kind SourceKind, file string) error {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	c.addLayer(kind, file, v.AllSettings())
+
+	return nil
+}
+
+// mergeLayer merges the settings of a source into the config
+// layer and records it, c.mut must be held
+func (c *Config) mergeLayer(kind SourceKind, name string, settings map[string]interface{}) error {
+	c.addLayer(kind, name, settings)
+
+	return c.v.MergeConfigMap(settings)
+}
+
+// setOverride records the value set by Set, c.mut must be held
+func (c *Config) setOverride(key string, value interface{}) {
+	c.src.overrides = replaceFlat(c.src.overrides, strings.ToLower(key), value)
+}
+
+// replaceFlat replaces values of the key in the flattened map
+func replaceFlat(m map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+
+	for k := range m {
+		if k == key || strings.HasPrefix(k, key+".") || strings.HasPrefix(key, k+".") {
+			delete(m, k)
+		}
+	}
+
+	return flatten(key, value, m)
+}
+
+// flatten puts leaf values of value into out with dotted lowercase keys
+func flatten(key string, value interface{}, out map[string]interface{}) map[string]interface{} {
+	if out == nil {
+		out = make(map[string]interface{})
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, map[interface{}]interface{}:
+		for k, v := range cast.ToStringMap(value) {
+			flatten(joinKey(key, strings.ToLower(k)), v, out)
+		}
+	default:
+		if key != "" {
+			out[key] = value
+		}
+	}
+
+	return out
+}
+
+// copyValue copies maps and slices in value deeply
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			m[k] = copyValue(elem)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, elem := range v {
+			m[k] = copyValue(elem)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, elem := range v {
+			s[i] = copyValue(elem)
+		}
+		return s
+	}
+
+	return value
+}
+
+// flatLookup finds the value of the key in the flattened map,
+// and a map is rebuilt if the key has nested keys
+func flatLookup(m map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+
+	nested := unflatten(m, key+".")
+	if len(nested) == 0 {
+		return nil, false
+	}
+
+	return nested, true
+}
+
+// unflatten rebuilds a nested map from keys with the prefix in the flattened map
+func unflatten(m map[string]interface{}, prefix string) map[string]interface{} {
+	var keys []string
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	nested := make(map[string]interface{})
+	for _, k := range keys {
+		path := strings.Split(strings.TrimPrefix(k, prefix), ".")
+		sub := nested
+		for _, p := range path[:len(path)-1] {
+			next, ok := sub[p].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				sub[p] = next
+			}
+			sub = next
+		}
+		sub[path[len(path)-1]] = copyValue(m[k])
+	}
+
+	return nested
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Config_Explain(t *testing.T) {
+	setProfileEnv(t, "prod")
+	require.NoError(t, LoadE(profilePath))
+	defer reset()
+
+	t.Run("file", func(t *testing.T) {
+		e := Explain("Http.Port")
+		assert.Equal(t, "http.port", e.Key)
+		assert.Equal(t, int64(8080), e.Value)
+		require.NotNil(t, e.Source)
+		assert.Equal(t, SourceFile, e.Source.Kind)
+		assert.Equal(t, "config.toml", filepath.Base(e.Source.Name))
+		assert.Len(t, e.Shadowed, 0)
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		e := Explain("env")
+		require.NotNil(t, e.Source)
+		assert.Equal(t, Source{Kind: SourceProfile, Name: e.Source.Name, Value: "prod"}, *e.Source)
+		assert.Equal(t, "config.prod.toml", filepath.Base(e.Source.Name))
+		require.Len(t, e.Shadowed, 1)
+		assert.Equal(t, SourceFile, e.Shadowed[0].Kind)
+		assert.Equal(t, "dev", e.Shadowed[0].Value)
+
+		e = Explain("http.host")
+		assert.Equal(t, SourceProfile, e.Source.Kind)
+		assert.Equal(t, filepath.Join("prod", "http.toml"), filepath.Join(filepath.Base(filepath.Dir(e.Source.Name)), filepath.Base(e.Source.Name)))
+	})
+
+	t.Run("dir and nested", func(t *testing.T) {
+		require.NoError(t, LoadAll("./testdata/all"))
+
+		e := Explain("http")
+		require.NotNil(t, e.Source)
+		assert.Equal(t, SourceDir, e.Source.Kind)
+		assert.Equal(t, "http.toml", filepath.Base(e.Source.Name))
+		assert.Equal(t, map[string]interface{}{"host": "127.0.0.1", "port": int64(8888)}, e.Source.Value)
+		require.Len(t, e.Shadowed, 2)
+		assert.Equal(t, SourceProfile, e.Shadowed[0].Kind)
+		assert.Equal(t, SourceFile, e.Shadowed[1].Kind)
+	})
+
+	t.Run("all kinds", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "dawn")
+		require.NoError(t, err)
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		old := dotEnvFile
+		dotEnvFile = filepath.Join(dir, ".env")
+		defer func() { dotEnvFile = old }()
+		require.NoError(t, ioutil.WriteFile(dotEnvFile, []byte("DAWN_EXPLAIN_APP_NAME=dotenv\n"), 0600))
+
+		SetDefaults(map[string]interface{}{"app.name": "default"})
+		MergeConfigMap(map[string]interface{}{"App": map[string]interface{}{"Name": "merged"}})
+		assert.Equal(t, "merged", GetString("app.name", "local"))
+
+		LoadEnv("DAWN_EXPLAIN")
+		require.NoError(t, os.Setenv("DAWN_EXPLAIN_APP_NAME", "env"))
+		defer func() { _ = os.Unsetenv("DAWN_EXPLAIN_APP_NAME") }()
+
+		Set("app.name", "set")
+
+		e := Explain("app.name")
+		assert.Equal(t, "set", e.Value)
+		assert.Equal(t, SourceSet, e.Source.Kind)
+		kinds := []SourceKind{}
+		for _, s := range e.Shadowed {
+			kinds = append(kinds, s.Kind)
+		}
+		assert.Equal(t, []SourceKind{SourceEnv, SourceMerge, SourceDefault}, kinds)
+		assert.Equal(t, "DAWN_EXPLAIN_APP_NAME", e.Shadowed[0].Name)
+
+		require.NoError(t, os.Setenv("DAWN_EXPLAIN_APP_NAME", "dotenv"))
+		e = Explain("app.name")
+		assert.Equal(t, SourceDotEnv, e.Shadowed[0].Kind)
+
+		assert.Equal(t, `app.name: set
+  set: set (winner)
+  dotenv DAWN_EXPLAIN_APP_NAME: dotenv (shadowed)
+  merge: merged (shadowed)
+  default: default (shadowed)`, e.String())
+	})
+
+	t.Run("sub", func(t *testing.T) {
+		c := Sub("Sql").Sub("connections")
+		c.SetDefaults(map[string]interface{}{"testing.timeout": "5s"})
+		assert.Equal(t, "5s", c.GetString("testing.timeout"))
+
+		e := c.Explain("testing.timeout")
+		assert.Equal(t, "sql.connections.testing.timeout", e.Key)
+		assert.Equal(t, SourceDefault, e.Source.Kind)
+
+		c.Set("testing.driver", "mysql")
+		e = c.Explain("testing.driver")
+		assert.Equal(t, "mysql", e.Value)
+		assert.Equal(t, SourceSet, e.Source.Kind)
+		require.Len(t, e.Shadowed, 1)
+		assert.Equal(t, SourceDir, e.Shadowed[0].Kind)
+	})
+
+	t.Run("masked", func(t *testing.T) {
+		require.NoError(t, os.Setenv("DAWN_EXPLAIN_PASS", "secret"))
+		defer func() { _ = os.Unsetenv("DAWN_EXPLAIN_PASS") }()
+
+		Set("user", "${env:DAWN_EXPLAIN_PASS}")
+		e := Explain("user")
+		assert.Equal(t, maskedValue, e.Value)
+		assert.Equal(t, "${env:DAWN_EXPLAIN_PASS}", e.Source.Value)
+
+		// plaintext values of secret keys are masked in all sources
+		MergeConfigMap(map[string]interface{}{"redis": map[string]interface{}{"password": "plain"}})
+		Set("redis.password", "${env:DAWN_EXPLAIN_PASS}")
+		e = Explain("redis.password")
+		assert.Equal(t, maskedValue, e.Value)
+		assert.Equal(t, maskedValue, e.Source.Value)
+		require.Len(t, e.Shadowed, 1)
+		assert.Equal(t, maskedValue, e.Shadowed[0].Value)
+		assert.NotContains(t, e.String(), "plain")
+	})
+
+	t.Run("not set", func(t *testing.T) {
+		e := Explain("non")
+		assert.Nil(t, e.Source)
+		assert.Equal(t, "non: not set", e.String())
+	})
+}
+
+func Test_Config_ExplainReload(t *testing.T) {
+	dir := profileDir(t)
+	file := filepath.Join(dir, "config.toml")
+
+	c := New(file)
+	c.MergeConfigMap(map[string]interface{}{"env": "merged"})
+	assert.Equal(t, SourceMerge, c.Explain("env").Source.Kind)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`env = "test"`), 0600))
+	require.NoError(t, c.Reload())
+
+	// the merged map is still the winner over the file read in again
+	e := c.Explain("env")
+	assert.Equal(t, "merged", e.Value)
+	assert.Equal(t, SourceMerge, e.Source.Kind)
+	require.Len(t, e.Shadowed, 1)
+	assert.Equal(t, SourceFile, e.Shadowed[0].Kind)
+	assert.Equal(t, "test", e.Shadowed[0].Value)
+}
+
+func Test_Config_FlatLookup(t *testing.T) {
+	m := flatten("", map[string]interface{}{
+		"A": map[interface{}]interface{}{"B": 1, "c": map[string]interface{}{"d": 2}},
+		"e": 3,
+	}, nil)
+	assert.Equal(t, map[string]interface{}{"a.b": 1, "a.c.d": 2, "e": 3}, m)
+
+	v, ok := flatLookup(m, "a")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"b": 1, "c": map[string]interface{}{"d": 2}}, v)
+
+	_, ok = flatLookup(m, "a.b.c")
+	assert.False(t, ok)
+
+	m = replaceFlat(m, "a.c", 4)
+	assert.Equal(t, map[string]interface{}{"a.b": 1, "a.c": 4, "e": 3}, m)
+	m = replaceFlat(m, "a", "x")
+	assert.Equal(t, map[string]interface{}{"a": "x", "e": 3}, m)
+}
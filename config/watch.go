@@ -0,0 +1,80 @@
+package config
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watch watches the config file and the profile file in the same
+// directory, and reloads config when one of them changes. The whole
+// directory is watched to pick up renames and atomic saves.
+func (c *Config) watch() error {
+	filename := c.v.ConfigFileUsed()
+	if filename == "" {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	configFile := filepath.Clean(filename)
+	configDir, _ := filepath.Split(configFile)
+
+	var profileFile string
+	if c.profile != nil {
+		if f := c.profile.usedFile(); f != "" {
+			profileFile = filepath.Clean(f)
+		}
+	}
+	realConfigFile, _ := filepath.EvalSymlinks(filename)
+
+	if err = watcher.Add(configDir); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer func() { _ = watcher.Close() }()
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				// stop watching once the global config is replaced
+				c.mut.RLock()
+				replaced := c.replacedBy != nil
+				c.mut.RUnlock()
+				if replaced {
+					return
+				}
+
+				// only care about the config file with the following cases:
+				// 1 - the config file was modified or created
+				// 2 - the real path to the config file changed (eg: k8s ConfigMap replacement)
+				currentConfigFile, _ := filepath.EvalSymlinks(filename)
+				name := filepath.Clean(event.Name)
+				if ((name == configFile || name == profileFile) && event.Op&(fsnotify.Write|fsnotify.Create) != 0) ||
+					(currentConfigFile != "" && currentConfigFile != realConfigFile) {
+					realConfigFile = currentConfigFile
+					if err := c.Reload(); err != nil {
+						log.Printf("config: %v", err)
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("config: watcher error: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Config_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dawn")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	file := filepath.Join(dir, "config.toml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`foo = "bar"`), 0600))
+
+	c, err := NewE(file)
+	require.NoError(t, err)
+	require.NoError(t, c.watch())
+
+	reloaded := make(chan struct{}, 10)
+	c.OnReload(func() { reloaded <- struct{}{} })
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`foo = "baz"`), 0600))
+
+	select {
+	case <-reloaded:
+		assert.Equal(t, "baz", c.GetString("foo"))
+	case <-time.After(time.Second * 3):
+		assert.Fail(t, "should reload config")
+	}
+
+	t.Run("no file", func(t *testing.T) {
+		assert.NoError(t, New().watch())
+	})
+
+	t.Run("invalid dir", func(t *testing.T) {
+		c := New()
+		c.v.SetConfigFile(filepath.Join(dir, "non", "config.toml"))
+		assert.Error(t, c.watch())
+	})
+}
@@ -0,0 +1,167 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-dawn/dawn/config"
+)
+
+const envUmask = "DAWN_DAEMON_UMASK"
+
+// workerAttrs are the process attributes of workers set up
+// in the master
+var workerAttrs *workerAttr
+
+// workerAttr is the process attributes of workers
+type workerAttr struct {
+	cred *credential
+	dir  string
+	// umask is in octal, empty means not set
+	umask    string
+	env      []string
+	clearEnv bool
+}
+
+// setupWorkerAttr reads process attributes of workers by config:
+//  [Daemon]
+//  # user and group to run workers as, by name or id. The master
+//  # keeps its privilege to bind the listener and restart workers.
+//  User = "nobody"
+//  Group = "nogroup"
+//  # working directory of workers
+//  Dir = "/var/www"
+//  # umask of workers in octal, applied when workers call Run
+//  Umask = "022"
+//  # environment variables of workers, which also inherit the ones
+//  # of the master unless ClearEnv is true
+//  Env = ["KEY=value"]
+//  ClearEnv = false
+func setupWorkerAttr() (err error) {
+	a := &workerAttr{
+		dir:      config.GetString("daemon.dir"),
+		env:      config.GetStringSlice("daemon.env"),
+		clearEnv: config.GetBool("daemon.clearEnv"),
+	}
+
+	if a.cred, err = lookupCredential(config.GetString("daemon.user"), config.GetString("daemon.group")); err != nil {
+		return
+	}
+
+	if a.dir != "" {
+		fi, err := os.Stat(a.dir)
+		if err == nil && !fi.IsDir() {
+			err = errors.New("not a directory")
+		}
+		if err != nil {
+			return fmt.Errorf("dawn: invalid worker dir %s: %s", a.dir, err)
+		}
+	}
+
+	if a.umask, err = parseUmask(config.Get("daemon.umask")); err != nil {
+		return
+	}
+
+	for _, kv := range a.env {
+		if strings.IndexByte(kv, '=') <= 0 {
+			return fmt.Errorf("dawn: invalid worker env %q, should be KEY=value", kv)
+		}
+	}
+
+	workerAttrs = a
+
+	return nil
+}
+
+func teardownWorkerAttr() {
+	workerAttrs = nil
+}
+
+// parseUmask returns umask in octal from an octal string like
+// "022" or an integer like 0o022 in toml
+func parseUmask(v interface{}) (string, error) {
+	var (
+		mask uint64
+		err  error
+	)
+
+	switch m := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		mask, err = strconv.ParseUint(m, 8, 32)
+	case int:
+		mask, err = uint64(m), nil
+		if m < 0 {
+			err = strconv.ErrRange
+		}
+	case int64:
+		mask, err = uint64(m), nil
+		if m < 0 {
+			err = strconv.ErrRange
+		}
+	default:
+		err = strconv.ErrSyntax
+	}
+
+	if err == nil && mask > 0777 {
+		err = strconv.ErrRange
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("dawn: invalid umask %v: %s", v, err)
+	}
+
+	return strconv.FormatUint(mask, 8), nil
+}
+
+// apply sets the attributes to the command of a worker
+func (a *workerAttr) apply(cmd *exec.Cmd) {
+	if a.clearEnv {
+		// keep variables used by the daemon only
+		env := cmd.Env[:0]
+		for _, kv := range cmd.Env {
+			if strings.HasPrefix(kv, envDaemon) {
+				env = append(env, kv)
+			}
+		}
+		cmd.Env = env
+	}
+	cmd.Env = append(cmd.Env, a.env...)
+
+	if a.umask != "" {
+		cmd.Env = append(cmd.Env, envUmask+"="+a.umask)
+	}
+
+	if a.dir != "" {
+		cmd.Dir = a.dir
+		// a relative path of the binary is relative to the master
+		if p, err := filepath.Abs(cmd.Path); err == nil {
+			cmd.Path = p
+		}
+	}
+
+	setCredential(cmd.SysProcAttr, a.cred)
+}
+
+// applyUmask sets the umask passed by the master in a worker
+func applyUmask() error {
+	s, ok := os.LookupEnv(envUmask)
+	if !ok {
+		return nil
+	}
+
+	mask, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return fmt.Errorf("dawn: invalid umask %s: %s", s, err)
+	}
+
+	setUmask(int(mask))
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupWorkerAttr(t *testing.T) {
+	at := assert.New(t)
+	defer teardownWorkerAttr()
+
+	t.Run("default", func(t *testing.T) {
+		require.Nil(t, setupWorkerAttr())
+		at.Equal(&workerAttr{}, workerAttrs)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		dir := tempDir(t)
+		config.Set("daemon.dir", dir)
+		defer config.Set("daemon.dir", nil)
+		config.Set("daemon.umask", "027")
+		defer config.Set("daemon.umask", nil)
+		config.Set("daemon.env", []string{"APP_ENV=prod"})
+		defer config.Set("daemon.env", nil)
+		config.Set("daemon.clearEnv", true)
+		defer config.Set("daemon.clearEnv", nil)
+
+		require.Nil(t, setupWorkerAttr())
+		at.Equal(dir, workerAttrs.dir)
+		at.Equal("27", workerAttrs.umask)
+		at.Equal([]string{"APP_ENV=prod"}, workerAttrs.env)
+		at.True(workerAttrs.clearEnv)
+	})
+
+	t.Run("dir error", func(t *testing.T) {
+		config.Set("daemon.dir", filepath.Join(tempDir(t), "not-exist"))
+		defer config.Set("daemon.dir", nil)
+		at.NotNil(setupWorkerAttr())
+
+		f := filepath.Join(tempDir(t), "file")
+		require.Nil(t, ioutil.WriteFile(f, nil, 0644))
+		config.Set("daemon.dir", f)
+		at.NotNil(setupWorkerAttr())
+	})
+
+	t.Run("umask error", func(t *testing.T) {
+		config.Set("daemon.umask", "9")
+		defer config.Set("daemon.umask", nil)
+
+		at.NotNil(setupWorkerAttr())
+	})
+
+	t.Run("env error", func(t *testing.T) {
+		config.Set("daemon.env", []string{"=value"})
+		defer config.Set("daemon.env", nil)
+
+		at.NotNil(setupWorkerAttr())
+	})
+}
+
+func TestParseUmask(t *testing.T) {
+	at := assert.New(t)
+
+	for v, expected := range map[interface{}]string{
+		nil:       "",
+		"022":     "22",
+		"0":       "0",
+		18:        "22",
+		int64(63): "77",
+		"0777":    "777",
+	} {
+		mask, err := parseUmask(v)
+		at.Nil(err, v)
+		at.Equal(expected, mask, v)
+	}
+
+	for _, v := range []interface{}{"a", "1000", -1, int64(-1), 512, 1.5} {
+		_, err := parseUmask(v)
+		at.NotNil(err, v)
+	}
+}
+
+func TestWorkerAttrApply(t *testing.T) {
+	at := assert.New(t)
+
+	dir := tempDir(t)
+	a := &workerAttr{dir: dir, umask: "22", env: []string{"APP_ENV=prod"}, clearEnv: true}
+
+	cmd := exec.Command("./app")
+	cmd.Env = []string{"HOME=/root", envDaemon + "=", envDaemonWorker + "="}
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+
+	a.apply(cmd)
+
+	at.Equal([]string{envDaemon + "=", envDaemonWorker + "=", "APP_ENV=prod", envUmask + "=22"}, cmd.Env)
+	at.Equal(dir, cmd.Dir)
+	at.True(filepath.IsAbs(cmd.Path))
+}
+
+func TestSpawnWorkerAttr(t *testing.T) {
+	at := assert.New(t)
+
+	config.Set("daemon.env", []string{"APP_ENV=prod"})
+	defer config.Set("daemon.env", nil)
+
+	require.Nil(t, setupWorkerAttr())
+	defer teardownWorkerAttr()
+
+	deck.SetupEnvs(deck.Envs{envDaemon: ""})
+	defer deck.TeardownEnvs()
+	deck.SetupCmdError()
+	defer deck.TeardownCmd()
+
+	cmd, err := spawn(false, nil, nil)
+	at.NotNil(err)
+	at.Equal("APP_ENV=prod", cmd.Env[len(cmd.Env)-1])
+}
+
+func TestApplyUmask(t *testing.T) {
+	at := assert.New(t)
+
+	at.Nil(applyUmask())
+
+	deck.SetupEnvs(deck.Envs{envUmask: "a"})
+	at.NotNil(applyUmask())
+	deck.TeardownEnvs()
+
+	deck.SetupEnvs(deck.Envs{envDaemonWorker: "", envUmask: "22"})
+	defer deck.TeardownEnvs()
+	at.Nil(RunE())
+}
@@ -0,0 +1,169 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-dawn/pkg/deck"
+)
+
+var (
+	// ErrRunning means the daemon is already running
+	ErrRunning = errors.New("dawn: daemon is already running")
+	// ErrNotRunning means the daemon is not running
+	ErrNotRunning = errors.New("dawn: daemon is not running")
+)
+
+var (
+	stopInterval = time.Millisecond * 100
+	// stopGrace is the extra time for the master to exit
+	// after the worker is stopped
+	stopGrace = time.Second * 5
+)
+
+// Status returns the pid in pid file and whether the master
+// process holding it is running.
+func Status() (pid int, running bool, err error) {
+	name := pidFilePath()
+
+	if pid, err = readPid(name); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	running, err = isLocked(name)
+
+	return
+}
+
+// Stop asks the master process to stop and waits for it to
+// exit. The master stops the worker first, and kills it if it
+// doesn't exit within daemon.stopTimeout.
+func Stop() error {
+	pid, running, err := Status()
+	if err != nil {
+		return err
+	}
+
+	if !running {
+		return ErrNotRunning
+	}
+
+	p, err := os.FindProcess(pid)
+	if err == nil {
+		err = terminate(p)
+	}
+	if err != nil {
+		return fmt.Errorf("dawn: failed to stop daemon (pid %d): %s", pid, err)
+	}
+
+	timeout := stopTimeout() + stopGrace
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); {
+		if _, running, err = Status(); err != nil || !running {
+			return err
+		}
+		time.Sleep(stopInterval)
+	}
+
+	return fmt.Errorf("dawn: daemon (pid %d) didn't stop in %s", pid, timeout)
+}
+
+// Restart stops the running daemon if any and starts a new one
+func Restart() error {
+	if !isDaemon() {
+		if err := Stop(); err != nil && !errors.Is(err, ErrNotRunning) {
+			return err
+		}
+	}
+
+	return RunE()
+}
+
+// Upgrade asks the master process to start a new worker from the
+// current binary and stop the old one once the new one is ready.
+// It returns without waiting for the upgrade to be done.
+func Upgrade() error {
+	pid, running, err := Status()
+	if err != nil {
+		return err
+	}
+
+	if !running {
+		return ErrNotRunning
+	}
+
+	p, err := os.FindProcess(pid)
+	if err == nil {
+		err = signalUpgrade(p)
+	}
+	if err != nil {
+		return fmt.Errorf("dawn: failed to upgrade daemon (pid %d): %s", pid, err)
+	}
+
+	return nil
+}
+
+// Command handles the daemon subcommand in args[0], which is one
+// of start, stop, restart, upgrade and status, e.g.
+//  if exit, err := daemon.Command(os.Args[1:]); exit || err != nil {
+//  	// print err and exit
+//  }
+// start and restart run the daemon like RunE and return false in
+// the worker process to let it continue. stop, upgrade and status
+// print the result and return true. status also prints workers got
+// from the control socket. Other args are left to the caller.
+func Command(args []string) (exit bool, err error) {
+	if len(args) == 0 {
+		return
+	}
+
+	switch args[0] {
+	case "start":
+		err = RunE()
+	case "restart":
+		err = Restart()
+	case "stop":
+		exit = true
+		pid, _, _ := Status()
+		if err = Stop(); err == nil {
+			_, _ = fmt.Fprintf(deck.Stdout, "dawn: daemon (pid %d) stopped\n", pid)
+		}
+	case "upgrade":
+		exit = true
+		pid, _, _ := Status()
+		if err = Upgrade(); err == nil {
+			_, _ = fmt.Fprintf(deck.Stdout, "dawn: daemon (pid %d) is upgrading\n", pid)
+		}
+	case "status":
+		exit = true
+		pid, running, e := Status()
+		if err = e; err != nil {
+			return
+		}
+		if running {
+			_, _ = fmt.Fprintf(deck.Stdout, "dawn: daemon is running (pid %d)\n", pid)
+			printWorkers()
+		} else {
+			_, _ = fmt.Fprintln(deck.Stdout, "dawn: daemon is not running")
+		}
+	}
+
+	return
+}
+
+// printWorkers prints workers if the control socket is available
+func printWorkers() {
+	workers, err := NewClient().Workers()
+	if err != nil {
+		return
+	}
+
+	for _, w := range workers {
+		_, _ = fmt.Fprintf(deck.Stdout, "  worker #%d: pid %d, uptime %s, restarts %d\n",
+			w.ID, w.Pid, w.Uptime.Round(time.Second), w.Restarts)
+	}
+}
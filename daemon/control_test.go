@@ -0,0 +1,204 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	at := assert.New(t)
+
+	name := filepath.Join(tempDir(t), "dawn.pid")
+	config.Set("daemon.pidFile", name)
+	defer config.Set("daemon.pidFile", nil)
+
+	t.Run("not exist", func(t *testing.T) {
+		pid, running, err := Status()
+		at.Nil(err)
+		at.False(running)
+		at.Equal(0, pid)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		at.Nil(ioutil.WriteFile(name, []byte("123\n"), 0644))
+
+		pid, running, err := Status()
+		at.Nil(err)
+		at.False(running)
+		at.Equal(123, pid)
+	})
+
+	t.Run("running", func(t *testing.T) {
+		w := startHelper(t, "lock")
+		defer func() { _ = w.cmd.Process.Kill() }()
+
+		pid, running, err := Status()
+		at.Nil(err)
+		at.True(running)
+		at.Equal(w.pid(), pid)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		at.Nil(ioutil.WriteFile(name, []byte("pid"), 0644))
+
+		_, _, err := Status()
+		at.NotNil(err)
+	})
+}
+
+func TestStop(t *testing.T) {
+	at := assert.New(t)
+
+	config.Set("daemon.pidFile", filepath.Join(tempDir(t), "dawn.pid"))
+	defer config.Set("daemon.pidFile", nil)
+
+	t.Run("not running", func(t *testing.T) {
+		at.True(errors.Is(Stop(), ErrNotRunning))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		startHelper(t, "lock")
+
+		at.Nil(Stop())
+
+		_, running, err := Status()
+		at.Nil(err)
+		at.False(running)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		config.Set("daemon.stopTimeout", "100ms")
+		defer config.Set("daemon.stopTimeout", nil)
+		stopGrace = 0
+		defer func() { stopGrace = time.Second * 5 }()
+
+		w := startHelper(t, "lock-ignore")
+		defer func() { _ = w.cmd.Process.Kill() }()
+
+		at.NotNil(Stop())
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	at := assert.New(t)
+
+	config.Set("daemon.pidFile", filepath.Join(tempDir(t), "dawn.pid"))
+	defer config.Set("daemon.pidFile", nil)
+
+	at.True(errors.Is(Upgrade(), ErrNotRunning))
+
+	w := startHelper(t, "lock")
+	defer func() { _ = w.cmd.Process.Kill() }()
+
+	deck.RedirectStdout()
+	exit, err := Command([]string{"upgrade"})
+	at.True(exit)
+	at.Nil(err)
+	at.Equal("dawn: daemon (pid "+strconv.Itoa(w.pid())+") is upgrading\n", deck.DumpStdout())
+}
+
+func TestRestart(t *testing.T) {
+	config.Set("daemon.pidFile", filepath.Join(tempDir(t), "dawn.pid"))
+	defer config.Set("daemon.pidFile", nil)
+
+	deck.SetupCmdError()
+	defer deck.TeardownCmd()
+
+	assert.NotNil(t, Restart())
+}
+
+func TestCommand(t *testing.T) {
+	at := assert.New(t)
+
+	config.Set("daemon.pidFile", filepath.Join(tempDir(t), "dawn.pid"))
+	defer config.Set("daemon.pidFile", nil)
+
+	t.Run("none", func(t *testing.T) {
+		exit, err := Command(nil)
+		at.False(exit)
+		at.Nil(err)
+
+		exit, err = Command([]string{"serve"})
+		at.False(exit)
+		at.Nil(err)
+	})
+
+	t.Run("start", func(t *testing.T) {
+		deck.SetupCmdError()
+		defer deck.TeardownCmd()
+
+		exit, err := Command([]string{"start"})
+		at.False(exit)
+		at.NotNil(err)
+
+		exit, err = Command([]string{"restart"})
+		at.False(exit)
+		at.NotNil(err)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		deck.RedirectStdout()
+		exit, err := Command([]string{"status"})
+		at.True(exit)
+		at.Nil(err)
+		at.Equal("dawn: daemon is not running\n", deck.DumpStdout())
+
+		w := startHelper(t, "lock")
+
+		deck.RedirectStdout()
+		exit, err = Command([]string{"status"})
+		at.True(exit)
+		at.Nil(err)
+		at.Equal("dawn: daemon is running (pid "+strconv.Itoa(w.pid())+")\n", deck.DumpStdout())
+
+		deck.RedirectStdout()
+		exit, err = Command([]string{"stop"})
+		at.True(exit)
+		at.Nil(err)
+		at.Equal("dawn: daemon (pid "+strconv.Itoa(w.pid())+") stopped\n", deck.DumpStdout())
+
+		exit, err = Command([]string{"stop"})
+		at.True(exit)
+		at.True(errors.Is(err, ErrNotRunning))
+	})
+}
+
+func TestRunMaster(t *testing.T) {
+	at := assert.New(t)
+
+	config.Set("daemon.pidFile", filepath.Join(tempDir(t), "dawn.pid"))
+	defer config.Set("daemon.pidFile", nil)
+
+	t.Run("stop master", func(t *testing.T) {
+		deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "sleep"})
+		defer deck.TeardownEnvs()
+
+		deck.SetupCmd()
+		defer deck.TeardownCmd()
+
+		sigCh <- syscall.SIGTERM
+
+		at.Equal(0, run())
+	})
+
+	t.Run("already running", func(t *testing.T) {
+		w := startHelper(t, "lock")
+		defer func() { _ = w.cmd.Process.Kill() }()
+
+		at.True(errors.Is(RunE(), ErrRunning))
+
+		_, err := runMaster()
+		at.True(errors.Is(err, ErrRunning))
+	})
+}
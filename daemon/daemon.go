@@ -2,84 +2,191 @@ package daemon
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/go-dawn/dawn/config"
+	dawnlog "github.com/go-dawn/dawn/log"
 	"github.com/go-dawn/pkg/deck"
 )
 
 const envDaemon = "DAWN_DAEMON"
 const envDaemonWorker = "DAWN_DAEMON_WORKER"
 
-var stdoutLogFile *os.File
-var stderrLogFile *os.File
-var normalRunningTime = time.Second * 10
+var stdoutLogFile io.WriteCloser
+var stderrLogFile io.WriteCloser
+var listenerFile *os.File
+var sigCh = make(chan os.Signal, 1)
 var osExit = deck.OsExit
 var execCommand = deck.ExecCommand
 
+// Run runs current process in daemon mode and panics if
+// the daemon process can't be started.
 func Run() {
+	if err := RunE(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// RunE is the same as Run but returns error instead of panicking.
+// It fails if the daemon is already running with the same pid file.
+// In a worker process it applies [Daemon] Umask and returns.
+func RunE() error {
 	if isWorker() {
-		return
+		return applyUmask()
 	}
 
-	// Panic if the initial spawned daemon process has error
-	if _, err := spawn(true); err != nil {
-		panic(fmt.Sprintf("dawn: failed to run in daemon mode: %s", err))
+	if !isDaemon() {
+		pid, running, err := Status()
+		if err != nil {
+			return err
+		}
+		if running {
+			return fmt.Errorf("%w (pid %d)", ErrRunning, pid)
+		}
 	}
 
-	setupLogFiles()
+	if _, err := spawn(true, nil, nil); err != nil {
+		return fmt.Errorf("dawn: failed to run in daemon mode: %s", err)
+	}
+
+	code, err := runMaster()
+	if err != nil {
+		return err
+	}
+
+	osExit(code)
+
+	return nil
+}
+
+// runMaster holds the pid file, log files and control socket while
+// supervising workers, and returns the exit code of the master process.
+func runMaster() (int, error) {
+	pf, err := createPidFile(pidFilePath())
+	if err != nil {
+		return 0, err
+	}
+	defer pf.remove()
+
+	if err = setupLogFiles(); err != nil {
+		return 0, err
+	}
 	defer teardownLogFiles()
 
-	run()
+	if err = setupListener(); err != nil {
+		return 0, err
+	}
+	defer teardownListener()
+
+	if err = setupControlSocket(); err != nil {
+		return 0, err
+	}
+	defer teardownControlSocket()
+
+	if err = setupWorkerAttr(); err != nil {
+		return 0, err
+	}
+	defer teardownWorkerAttr()
+
+	stop := dawnlog.NotifyReopen()
+	defer stop()
+
+	return run(), nil
 }
 
-func run() {
-	var (
-		cmd    *exec.Cmd
-		err    error
-		count  int
-		start  time.Time
-		max    = config.GetInt("daemon.tries", 10)
-		logger = log.New(stderrLogFile, "", log.LstdFlags)
-	)
+// run keeps workers running and returns the exit code of the master.
+// SIGHUP is forwarded to workers to reload them, while SIGTERM and
+// SIGINT are forwarded to stop them and then the master. SIGUSR2
+// upgrades workers one by one, see upgrade. Workers are restarted
+// by the restart policy, see newRestartPolicy. Output and events of
+// workers are written with messages of the master, see newOutput.
+func run() int {
+	out, err := newOutput()
+	logger := out.logger()
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
 
-	for {
-		if count++; count > max {
-			break
-		}
+	logOutput = out
+	defer func() { logOutput = nil }()
 
-		start = time.Now()
-		if cmd, err = spawn(false); err != nil {
-			continue
-		}
+	m, err := newMaster(logger)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
 
-		err = cmd.Wait()
+	return m.run()
+}
 
-		logger.Printf("dawn: (pid:%d)%v exist with err: %v", cmd.Process.Pid, cmd.Args, err)
+// upgrade starts a new worker from the current binary and waits for
+// it to be ready within daemon.upgradeTimeout. Then the caller stops
+// the old worker gracefully, while the new one accepts connections
+// from the shared listener. The new worker is killed if it fails to
+// be ready or the upgrade is canceled by closing cancel.
+func upgrade(old *worker, logger *log.Logger, cancel <-chan struct{}) (*worker, error) {
+	w, err := startWorker(old.id)
+	if err != nil {
+		return nil, err
+	}
 
-		if time.Since(start) > normalRunningTime {
-			// reset count
-			count = 0
-		}
+	logger.Printf("dawn: upgrading worker #%d (pid:%d) to (pid:%d)", old.id, old.pid(), w.pid())
+
+	timeout := upgradeTimeout()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return w, nil
+	case <-w.done:
+		return nil, fmt.Errorf("new worker (pid:%d) exited before being ready: %v", w.pid(), w.err)
+	case <-timer.C:
+		_ = w.cmd.Process.Kill()
+		<-w.done
+		return nil, fmt.Errorf("new worker (pid:%d) isn't ready in %s", w.pid(), timeout)
+	case <-cancel:
+		_ = w.cmd.Process.Kill()
+		<-w.done
+		return nil, fmt.Errorf("new worker (pid:%d) is canceled", w.pid())
 	}
+}
 
-	logger.Printf("dawn: already attempted %d times", max)
+// stopTimeout returns the time to wait for the worker to stop by config:
+//  [Daemon]
+//  StopTimeout = "30s"
+func stopTimeout() time.Duration {
+	return config.GetDuration("daemon.stopTimeout", time.Second*30)
+}
 
-	osExit(1)
+// upgradeTimeout returns the time to wait for the new worker to be
+// ready on upgrade by config:
+//  [Daemon]
+//  UpgradeTimeout = "30s"
+func upgradeTimeout() time.Duration {
+	return config.GetDuration("daemon.upgradeTimeout", time.Second*30)
 }
 
-func spawn(skip bool) (cmd *exec.Cmd, err error) {
+// spawn starts the master process, or a worker process in the master
+// with the ready pipe and listener inherited and env appended. Output
+// of the worker goes to c if it's not nil, otherwise to log files.
+func spawn(skip bool, ready *os.File, c *capture, env ...string) (cmd *exec.Cmd, err error) {
 	if isDaemon() && skip {
 		return
 	}
 
-	args, env := setupArgsAndEnv()
+	args, environ := setupArgsAndEnv()
 
 	cmd = execCommand(args[0], args[1:]...)
+	cmd.Env = append(cmd.Env, environ...)
 	cmd.Env = append(cmd.Env, env...)
 	cmd.SysProcAttr = newSysProcAttr()
 
@@ -91,6 +198,24 @@ func spawn(skip bool) (cmd *exec.Cmd, err error) {
 		if stderrLogFile != nil {
 			cmd.Stderr = stderrLogFile
 		}
+
+		if c != nil {
+			cmd.Stdout, cmd.Stderr = c.stdoutW, c.stderrW
+		}
+
+		if ready != nil {
+			cmd.ExtraFiles = []*os.File{ready}
+			cmd.Env = append(cmd.Env, envReadyFd+"="+strconv.Itoa(readyFd))
+
+			if listenerFile != nil {
+				cmd.ExtraFiles = append(cmd.ExtraFiles, listenerFile)
+				cmd.Env = append(cmd.Env, envListenerFd+"="+strconv.Itoa(listenerFd))
+			}
+		}
+
+		if workerAttrs != nil {
+			workerAttrs.apply(cmd)
+		}
 	}
 
 	if err = cmd.Start(); err != nil {
@@ -105,6 +230,39 @@ func spawn(skip bool) (cmd *exec.Cmd, err error) {
 	return
 }
 
+// setupListener listens on the address shared by workers by config:
+//  [Daemon]
+//  Listen = ":3000"
+// Workers get the listener by Listen. SO_REUSEPORT is set to let
+// another master listen on the same address, e.g. while upgrading
+// the master itself.
+func setupListener() error {
+	addr := config.GetString("daemon.listen")
+	if addr == "" {
+		return nil
+	}
+
+	ln, err := listen(addr)
+	if err != nil {
+		return fmt.Errorf("dawn: failed to listen on %s: %s", addr, err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	// the duplicated file keeps the socket open
+	if listenerFile, err = ln.(*net.TCPListener).File(); err != nil {
+		return fmt.Errorf("dawn: failed to get file of listener: %s", err)
+	}
+
+	return nil
+}
+
+func teardownListener() {
+	if listenerFile != nil {
+		_ = listenerFile.Close()
+		listenerFile = nil
+	}
+}
+
 func setupArgsAndEnv() ([]string, []string) {
 	args, env := os.Args, os.Environ()
 	if !isDaemon() {
@@ -128,19 +286,50 @@ func isWorker() bool {
 	return ok
 }
 
-func setupLogFiles() {
-	var err error
+// setupLogFiles opens log files of workers' stdout and stderr,
+// which are rotated by config:
+//  [Daemon]
+//  StdoutLogFile = "stdout.log"
+//  StderrLogFile = "stderr.log"
+//  [Daemon.Rotate]
+//  MaxSize = 100 # megabytes
+//  Interval = "24h"
+//  MaxFiles = 10
+//  MaxAge = "168h"
+//  Compress = false
+// Log files are reopened on SIGUSR1.
+func setupLogFiles() (err error) {
+	c := config.Sub("daemon.rotate")
+
 	if f := config.GetString("daemon.stdoutLogFile"); f != "" {
-		if stdoutLogFile, err = os.OpenFile(filepath.Clean(f), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err != nil {
-			panic(fmt.Sprintf("dawn: failed to open stdout log file %s: %s", f, err))
+		if stdoutLogFile, err = openLogFile(f, c); err != nil {
+			return fmt.Errorf("dawn: failed to open stdout log file %s: %s", f, err)
 		}
 	}
 
 	if f := config.GetString("daemon.stderrLogFile"); f != "" {
-		if stderrLogFile, err = os.OpenFile(filepath.Clean(f), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err != nil {
-			panic(fmt.Sprintf("dawn: failed to open stderr log file %s: %s", f, err))
+		if filepath.Clean(f) == filepath.Clean(config.GetString("daemon.stdoutLogFile")) {
+			// share the file to avoid rotating it twice
+			stderrLogFile = stdoutLogFile
+		} else if stderrLogFile, err = openLogFile(f, c); err != nil {
+			// not closed by teardownLogFiles since it isn't deferred yet
+			if stdoutLogFile != nil {
+				_ = stdoutLogFile.Close()
+				stdoutLogFile = nil
+			}
+			return fmt.Errorf("dawn: failed to open stderr log file %s: %s", f, err)
 		}
 	}
+
+	return
+}
+
+func openLogFile(name string, c *config.Config) (io.WriteCloser, error) {
+	f, err := dawnlog.NewRotateFile(dawnlog.NewRotateConfig(name, c))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
 }
 
 func teardownLogFiles() {
@@ -1,18 +1,32 @@
 package daemon
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/go-dawn/dawn/config"
+	dawnlog "github.com/go-dawn/dawn/log"
 	"github.com/go-dawn/pkg/deck"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+const envTestWorker = "DAWN_TEST_WORKER"
+const envTestPidFile = "DAWN_TEST_PID_FILE"
+
 func TestRun(t *testing.T) {
 	at := assert.New(t)
 
+	config.Set("daemon.pidFile", filepath.Join(tempDir(t), "dawn.pid"))
+	defer config.Set("daemon.pidFile", nil)
+
 	t.Run("worker", func(t *testing.T) {
 		deck.SetupEnvs(deck.Envs{envDaemonWorker: ""})
 		defer deck.TeardownEnvs()
@@ -21,15 +35,15 @@ func TestRun(t *testing.T) {
 	})
 
 	t.Run("main success", func(t *testing.T) {
-		config.Set("daemon.tries", 1)
-
 		deck.SetupCmd()
 		defer deck.TeardownCmd()
+
+		calls := 0
 		deck.SetupOsExit(func(code int) {
-			if code == 0 {
+			// the master exits with 0 as the worker does
+			at.Equal(0, code)
+			if calls++; calls == 1 {
 				deck.SetupEnvs(deck.Envs{envDaemon: ""})
-			} else {
-				at.Equal(1, code)
 			}
 		})
 		defer deck.TeardownOsExit()
@@ -43,17 +57,27 @@ func TestRun(t *testing.T) {
 		defer deck.TeardownCmd()
 
 		at.Panics(Run)
+		at.NotNil(RunE())
 	})
 
 	t.Run("break master", func(t *testing.T) {
 		config.Set("daemon.tries", 1)
+		defer config.Set("daemon.tries", nil)
 
 		deck.SetupCmdError()
 		defer deck.TeardownCmd()
 		deck.SetupOsExit()
 		defer deck.TeardownOsExit()
 
-		run()
+		at.Equal(1, run())
+	})
+
+	t.Run("listen error", func(t *testing.T) {
+		config.Set("daemon.listen", "invalid")
+		defer config.Set("daemon.listen", nil)
+
+		_, err := runMaster()
+		at.NotNil(err)
 	})
 }
 
@@ -64,7 +88,7 @@ func TestSpawn(t *testing.T) {
 		deck.SetupEnvs(deck.Envs{envDaemon: ""})
 		defer deck.TeardownEnvs()
 
-		cmd, err := spawn(true)
+		cmd, err := spawn(true, nil, nil)
 		at.Nil(err)
 		at.Nil(cmd)
 	})
@@ -78,7 +102,7 @@ func TestSpawn(t *testing.T) {
 
 		stdoutLogFile, stderrLogFile = os.Stdout, os.Stderr
 
-		cmd, err := spawn(false)
+		cmd, err := spawn(false, nil, nil)
 
 		at.NotNil(err)
 		at.NotNil(cmd)
@@ -93,39 +117,123 @@ func TestSetupLogFiles(t *testing.T) {
 	defer func() {
 		_ = os.Remove(f.Name())
 	}()
+	defer config.Set("daemon.stdoutLogFile", nil)
+	defer config.Set("daemon.stderrLogFile", nil)
 
 	t.Run("success", func(t *testing.T) {
 		config.Set("daemon.stdoutLogFile", f.Name())
 		config.Set("daemon.stderrLogFile", f.Name())
 
-		setupLogFiles()
+		at.Nil(setupLogFiles())
 
 		at.NotNil(stdoutLogFile)
 		at.NotNil(stderrLogFile)
 	})
 
-	t.Run("stdout panic", func(t *testing.T) {
+	t.Run("rotate", func(t *testing.T) {
+		defer config.Set("daemon.rotate", nil)
+		config.Set("daemon.rotate.maxSize", 1)
+
+		at.Nil(setupLogFiles())
+		defer teardownLogFiles()
+
+		at.Equal(stdoutLogFile, stderrLogFile)
+
+		f, ok := stdoutLogFile.(*dawnlog.RotateFile)
+		at.True(ok)
+		at.Equal(filepath.Clean(f.Name()), filepath.Clean(config.GetString("daemon.stdoutLogFile")))
+	})
+
+	t.Run("stdout error", func(t *testing.T) {
 		config.Set("daemon.stdoutLogFile", ".")
 
-		at.Panics(setupLogFiles)
+		at.NotNil(setupLogFiles())
 	})
 
-	t.Run("stderr panic", func(t *testing.T) {
+	t.Run("stderr error", func(t *testing.T) {
 		config.Set("daemon.stdoutLogFile", f.Name())
 		config.Set("daemon.stderrLogFile", ".")
 
-		at.Panics(setupLogFiles)
+		at.NotNil(setupLogFiles())
+		// the stdout log file is closed
+		at.Nil(stdoutLogFile)
 	})
 }
 
 func TestTeardownLogFiles(t *testing.T) {
-	f := os.NewFile(1, "")
+	f, err := ioutil.TempFile(tempDir(t), "")
+	require.Nil(t, err)
 	stdoutLogFile, stderrLogFile = f, f
 	defer func() { stdoutLogFile, stderrLogFile = nil, nil }()
 
 	teardownLogFiles()
+
+	_, err = f.Write([]byte("closed"))
+	assert.NotNil(t, err)
 }
 
 func TestHelperCommand(t *testing.T) {
-	deck.HandleCommand(func(args []string, expectStderr bool) {})
+	deck.HandleCommand(func(args []string, expectStderr bool) {
+		switch os.Getenv(envTestWorker) {
+		case "sleep":
+			ch := make(chan os.Signal, 1)
+			signal.Notify(ch, syscall.SIGHUP)
+			Ready()
+			select {
+			case <-ch:
+				os.Exit(3)
+			case <-time.After(time.Second * 10):
+			}
+		case "term":
+			ch := make(chan os.Signal, 1)
+			signal.Notify(ch, syscall.SIGTERM)
+			Ready()
+			select {
+			case <-ch:
+				os.Exit(0)
+			case <-time.After(time.Second * 10):
+			}
+		case "ignore":
+			signal.Ignore(syscall.SIGTERM)
+			Ready()
+			time.Sleep(time.Second * 10)
+		case "lock", "lock-ignore":
+			if os.Getenv(envTestWorker) == "lock-ignore" {
+				signal.Ignore(syscall.SIGTERM)
+			}
+			if _, err := createPidFile(os.Getenv(envTestPidFile)); err != nil {
+				os.Exit(1)
+			}
+			Ready()
+			time.Sleep(time.Second * 10)
+		case "serve":
+			ln, err := Listen("")
+			if err != nil {
+				os.Exit(1)
+			}
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					os.Exit(1)
+				}
+				_, _ = conn.Write([]byte(strconv.Itoa(os.Getpid())))
+				_ = conn.Close()
+			}
+		case "echo":
+			fmt.Println("hello")
+			fmt.Fprintln(os.Stderr, "oops")
+		case "fail":
+			os.Exit(3)
+		case "hang":
+			time.Sleep(time.Second * 10)
+		}
+	})
+}
+
+// tempDir works like t.TempDir which is not available before go1.15
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dawn")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
 }
@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const envListenerFd = "DAWN_DAEMON_LISTENER_FD"
+const envReadyFd = "DAWN_DAEMON_READY_FD"
+
+// Inherited files of workers start from fd 3
+const (
+	readyFd    = 3
+	listenerFd = 4
+)
+
+var readyOnce sync.Once
+
+// Listen returns the listener inherited from the master in a worker
+// process if the master listens on [Daemon] Listen, otherwise it
+// listens on the tcp addr with SO_REUSEPORT, so that workers can
+// listen on the same addr. The worker is marked ready once the
+// listener starts accepting, e.g.
+//  ln, err := daemon.Listen(":3000")
+//  ...
+//  err = sloop.RunListenerUntilSignal(ln)
+func Listen(addr string) (net.Listener, error) {
+	ln, err := inheritedListener()
+	if err != nil {
+		return nil, err
+	}
+
+	if ln == nil {
+		if ln, err = listen(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	return &readyListener{Listener: ln}, nil
+}
+
+// listen listens on the tcp addr with SO_REUSEPORT
+func listen(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{Control: reusePort}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+func inheritedListener() (net.Listener, error) {
+	fd, ok := os.LookupEnv(envListenerFd)
+	if !ok || !isWorker() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return nil, fmt.Errorf("dawn: invalid listener fd %s: %s", fd, err)
+	}
+
+	f := os.NewFile(uintptr(n), "listener")
+	defer func() { _ = f.Close() }()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("dawn: failed to inherit listener: %s", err)
+	}
+
+	return ln, nil
+}
+
+// readyListener marks the worker ready on the first Accept
+type readyListener struct {
+	net.Listener
+}
+
+// Accept implements net.Listener
+func (l *readyListener) Accept() (net.Conn, error) {
+	Ready()
+	return l.Listener.Accept()
+}
+
+// Ready tells the master that the worker is ready to serve, which
+// is required by the master to finish an upgrade. It's called by
+// the listener returned by Listen and only works once.
+func Ready() {
+	readyOnce.Do(func() {
+		fd, ok := os.LookupEnv(envReadyFd)
+		if !ok || !isWorker() {
+			return
+		}
+
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return
+		}
+
+		f := os.NewFile(uintptr(n), "ready")
+		_, _ = f.Write([]byte{1})
+		_ = f.Close()
+	})
+}
@@ -0,0 +1,102 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/go-dawn/pkg/deck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen(t *testing.T) {
+	at := assert.New(t)
+
+	t.Run("listen", func(t *testing.T) {
+		ln, err := Listen("127.0.0.1:0")
+		require.Nil(t, err)
+		defer func() { _ = ln.Close() }()
+
+		_, ok := ln.(*readyListener)
+		at.True(ok)
+	})
+
+	t.Run("listen error", func(t *testing.T) {
+		_, err := Listen("invalid")
+		at.NotNil(err)
+	})
+
+	t.Run("inherit", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+		defer func() { _ = l.Close() }()
+
+		f, err := l.(*net.TCPListener).File()
+		require.Nil(t, err)
+		// Listen takes the ownership of the duplicated fd
+		fd, err := syscall.Dup(int(f.Fd()))
+		require.Nil(t, err)
+		_ = f.Close()
+
+		deck.SetupEnvs(deck.Envs{envDaemonWorker: "", envListenerFd: strconv.Itoa(fd)})
+		defer deck.TeardownEnvs()
+
+		ln, err := Listen("invalid")
+		require.Nil(t, err)
+		defer func() { _ = ln.Close() }()
+
+		at.Equal(l.Addr().String(), ln.Addr().String())
+	})
+
+	t.Run("invalid fd", func(t *testing.T) {
+		deck.SetupEnvs(deck.Envs{envDaemonWorker: "", envListenerFd: "fd"})
+		defer deck.TeardownEnvs()
+
+		_, err := Listen("")
+		at.NotNil(err)
+	})
+
+	t.Run("not listener", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		require.Nil(t, err)
+		defer func() { _ = w.Close() }()
+		fd, err := syscall.Dup(int(r.Fd()))
+		require.Nil(t, err)
+		_ = r.Close()
+
+		deck.SetupEnvs(deck.Envs{envDaemonWorker: "", envListenerFd: strconv.Itoa(fd)})
+		defer deck.TeardownEnvs()
+
+		_, err = Listen("")
+		at.NotNil(err)
+	})
+}
+
+func TestReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.Nil(t, err)
+	defer func() { _ = r.Close() }()
+	fd, err := syscall.Dup(int(w.Fd()))
+	require.Nil(t, err)
+	_ = w.Close()
+
+	deck.SetupEnvs(deck.Envs{envDaemonWorker: "", envReadyFd: strconv.Itoa(fd)})
+	defer deck.TeardownEnvs()
+
+	readyOnce = sync.Once{}
+	defer func() { readyOnce = sync.Once{} }()
+
+	Ready()
+	Ready()
+
+	b := make([]byte, 2)
+	n, err := r.Read(b)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
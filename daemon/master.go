@@ -0,0 +1,452 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+)
+
+// WorkerStatus is the status of a worker reported by the master
+type WorkerStatus struct {
+	// ID is the index of the worker, starting from 0
+	ID int `json:"id"`
+	// Pid is the pid of the running worker process, or 0 if the
+	// worker is waiting to be restarted or has exited
+	Pid int `json:"pid"`
+	// Uptime is the running time of the worker process
+	Uptime time.Duration `json:"uptime"`
+	// Restarts is the number of restarts after failures
+	Restarts int `json:"restarts"`
+}
+
+// slot keeps a worker running with its own restart policy
+type slot struct {
+	id       int
+	w        *worker
+	policy   *restartPolicy
+	restarts int
+	// pending means a restart is scheduled
+	pending bool
+	// restart means the worker is stopped to be restarted
+	restart bool
+}
+
+// master supervises workers. All fields except slots guarded
+// by mu are only accessed in the loop of run.
+type master struct {
+	logger  *log.Logger
+	started time.Time
+
+	mu    sync.Mutex
+	slots []*slot
+
+	exited    chan *worker
+	restartCh chan *slot
+	calls     chan *controlCall
+	upgraded  chan *upgradeResult
+	quit      chan struct{}
+	stopping  bool
+	// upgrading is closed to cancel the upgrade in progress
+	upgrading chan struct{}
+	kill      <-chan time.Time
+	code      int
+}
+
+// upgradeResult is the result of upgrading the old worker of a slot
+type upgradeResult struct {
+	s   *slot
+	old *worker
+	w   *worker
+	err error
+}
+
+// newMaster creates a master with workers by config:
+//  [Daemon]
+//  # number of workers, default to the number of CPUs if Listen
+//  # is set, otherwise 1
+//  Workers = 0
+// Without the shared listener, more than one worker only works if the
+// app listens by Listen, otherwise workers fail with address in use.
+// Every worker is restarted by its own restart policy.
+func newMaster(logger *log.Logger) (*master, error) {
+	n := config.GetInt("daemon.workers")
+	if n <= 0 {
+		n = 1
+		if config.GetString("daemon.listen") != "" {
+			n = runtime.NumCPU()
+		}
+	}
+
+	m := &master{
+		logger:    logger,
+		slots:     make([]*slot, n),
+		exited:    make(chan *worker),
+		restartCh: make(chan *slot),
+		calls:     make(chan *controlCall),
+		upgraded:  make(chan *upgradeResult),
+		quit:      make(chan struct{}),
+	}
+
+	for i := range m.slots {
+		p, err := newRestartPolicy()
+		if err != nil {
+			return nil, err
+		}
+		m.slots[i] = &slot{id: i, policy: p}
+	}
+
+	return m, nil
+}
+
+// run starts workers and supervises them until all of them exit,
+// and returns the exit code of the master. Commands from the control
+// socket are handled if it's set up.
+func (m *master) run() int {
+	m.started = time.Now()
+
+	signal.Notify(sigCh, masterSignals...)
+	defer signal.Stop(sigCh)
+	defer close(m.quit)
+
+	if controlListener != nil {
+		go m.serve(controlListener)
+	}
+
+	for _, s := range m.slots {
+		m.start(s)
+	}
+
+	for !m.finished() {
+		select {
+		case w := <-m.exited:
+			m.onExit(w)
+		case s := <-m.restartCh:
+			m.start(s)
+		case c := <-m.calls:
+			m.call(c)
+		case r := <-m.upgraded:
+			m.onUpgraded(r)
+		case sig := <-sigCh:
+			m.onSignal(sig)
+		case <-m.kill:
+			m.killAll()
+		}
+	}
+
+	return m.code
+}
+
+// finished reports whether no worker is running or to be restarted,
+// and no worker is being upgraded
+func (m *master) finished() bool {
+	if m.upgrading != nil {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.slots {
+		if s.w != nil || s.pending {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *master) start(s *slot) {
+	m.mu.Lock()
+	s.pending = false
+	m.mu.Unlock()
+
+	if m.stopping {
+		return
+	}
+
+	w, err := startWorker(s.id)
+	if err != nil {
+		m.logger.Printf("dawn: failed to start worker #%d: %v", s.id, err)
+		m.failed(s, err)
+		return
+	}
+
+	m.watch(s, w)
+}
+
+// watch sets the worker of the slot and notifies the loop on exit
+func (m *master) watch(s *slot, w *worker) {
+	m.mu.Lock()
+	s.w = w
+	m.mu.Unlock()
+
+	go func() {
+		<-w.done
+		select {
+		case m.exited <- w:
+		case <-m.quit:
+		}
+	}()
+}
+
+func (m *master) onExit(w *worker) {
+	s := m.slots[w.id]
+
+	m.mu.Lock()
+	current := s.w == w
+	restart := current && s.restart
+	if current {
+		s.w = nil
+		s.restart = false
+	}
+	m.mu.Unlock()
+
+	// replaced by upgrade and stopped in background
+	if !current {
+		return
+	}
+
+	m.logger.Printf("dawn: (pid:%d)%v exist with err: %v", w.pid(), w.cmd.Args, w.err)
+
+	if m.stopping {
+		return
+	}
+
+	if restart {
+		m.start(s)
+		return
+	}
+
+	if state := w.cmd.ProcessState; !s.policy.restartable(state) {
+		m.logger.Printf("dawn: worker #%d (pid:%d) exited with code %d, not restarting", s.id, w.pid(), state.ExitCode())
+		m.code = state.ExitCode()
+		return
+	}
+
+	m.failed(s, w.err)
+}
+
+// failed schedules a restart of the slot by the restart policy,
+// or stops all workers when giving up.
+func (m *master) failed(s *slot, err error) {
+	p := s.policy
+	if p.fail(time.Now()) {
+		m.logger.Printf("dawn: worker #%d failed %d times in %s, giving up", s.id, p.tries, p.window)
+		if err = giveUp(p.tries, err); err != nil {
+			m.logger.Print(err)
+		}
+		m.code = 1
+		m.stop(syscall.SIGTERM)
+		return
+	}
+
+	delay := p.backoff()
+
+	m.mu.Lock()
+	s.pending = true
+	s.restarts++
+	m.logger.Printf("dawn: restarting worker #%d in %s, restarts: %d", s.id, delay, s.restarts)
+	m.mu.Unlock()
+
+	time.AfterFunc(delay, func() {
+		select {
+		case m.restartCh <- s:
+		case <-m.quit:
+		}
+	})
+}
+
+func (m *master) onSignal(sig os.Signal) {
+	switch {
+	case sig == syscall.SIGHUP:
+		m.signal(sig)
+	case isUpgrade(sig):
+		if !m.stopping {
+			m.upgrade()
+		}
+	default:
+		m.logger.Printf("dawn: received signal %s, stopping workers", sig)
+		m.stop(sig)
+	}
+}
+
+// restart stops the worker with the id, which is started again
+// once it exits regardless of the restart policy
+func (m *master) restart(id int) error {
+	if id < 0 || id >= len(m.slots) {
+		return fmt.Errorf("dawn: invalid worker id %d", id)
+	}
+
+	if m.stopping {
+		return errors.New("dawn: daemon is stopping")
+	}
+
+	s := m.slots[id]
+
+	m.mu.Lock()
+	w := s.w
+	if w != nil {
+		s.restart = true
+	}
+	m.mu.Unlock()
+
+	if w == nil {
+		return fmt.Errorf("dawn: worker #%d is not running", id)
+	}
+
+	m.logger.Printf("dawn: restarting worker #%d (pid:%d)", id, w.pid())
+
+	return terminate(w.cmd.Process)
+}
+
+// stop forwards sig to workers to stop them, and kills them
+// if they don't exit within stopTimeout.
+func (m *master) stop(sig os.Signal) {
+	if !m.stopping {
+		m.stopping = true
+		m.kill = time.After(stopTimeout())
+
+		// cancel scheduled restarts
+		m.mu.Lock()
+		for _, s := range m.slots {
+			s.pending = false
+		}
+		m.mu.Unlock()
+
+		// the new worker being upgraded is killed
+		if m.upgrading != nil {
+			close(m.upgrading)
+		}
+	}
+
+	m.signal(sig)
+}
+
+func (m *master) killAll() {
+	for _, w := range m.workers() {
+		m.logger.Printf("dawn: worker #%d (pid:%d) didn't stop in %s, killing it", w.id, w.pid(), stopTimeout())
+		_ = w.cmd.Process.Kill()
+	}
+}
+
+func (m *master) signal(sig os.Signal) {
+	for _, w := range m.workers() {
+		_ = w.cmd.Process.Signal(sig)
+	}
+}
+
+// upgrade upgrades workers one by one in background, so that the
+// loop keeps handling signals, exits and calls meanwhile. It's
+// ignored if an upgrade is in progress.
+func (m *master) upgrade() {
+	if m.upgrading != nil {
+		m.logger.Print("dawn: upgrade is in progress, ignoring")
+		return
+	}
+
+	m.upgrading = make(chan struct{})
+	m.upgradeFrom(0)
+}
+
+// upgradeFrom upgrades the first running worker from the slot id
+// in background, and the result is sent to the loop
+func (m *master) upgradeFrom(id int) {
+	for ; id < len(m.slots); id++ {
+		s := m.slots[id]
+
+		m.mu.Lock()
+		old := s.w
+		m.mu.Unlock()
+
+		if old == nil {
+			continue
+		}
+
+		cancel := m.upgrading
+		go func() {
+			w, err := upgrade(old, m.logger, cancel)
+			m.upgraded <- &upgradeResult{s: s, old: old, w: w, err: err}
+		}()
+
+		return
+	}
+
+	m.upgrading = nil
+}
+
+// onUpgraded replaces the old worker with the new one and upgrades
+// the next worker. The old worker is stopped after being replaced, so
+// that its exit isn't taken as a failure of the slot. The new worker is
+// stopped if the master is stopping or the old one has exited meanwhile,
+// e.g. restarted after a crash.
+func (m *master) onUpgraded(r *upgradeResult) {
+	s := r.s
+
+	if r.err != nil {
+		m.logger.Printf("dawn: failed to upgrade worker #%d (pid:%d): %v", s.id, r.old.pid(), r.err)
+	} else {
+		m.mu.Lock()
+		current := s.w == r.old
+		m.mu.Unlock()
+
+		switch {
+		case !current:
+			m.logger.Printf("dawn: worker #%d changed while upgrading, stopping (pid:%d)", s.id, r.w.pid())
+			go r.w.stop(m.logger, stopTimeout())
+		default:
+			m.watch(s, r.w)
+			go r.old.stop(m.logger, stopTimeout())
+			if m.stopping {
+				_ = terminate(r.w.cmd.Process)
+			}
+		}
+	}
+
+	if m.stopping {
+		m.upgrading = nil
+		return
+	}
+
+	m.upgradeFrom(s.id + 1)
+}
+
+// workers returns running workers
+func (m *master) workers() []*worker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	workers := make([]*worker, 0, len(m.slots))
+	for _, s := range m.slots {
+		if s.w != nil {
+			workers = append(workers, s.w)
+		}
+	}
+
+	return workers
+}
+
+// status returns status of all workers, it's safe
+// for concurrent use.
+func (m *master) status() []WorkerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := make([]WorkerStatus, len(m.slots))
+	for i, s := range m.slots {
+		status[i] = WorkerStatus{ID: s.id, Restarts: s.restarts}
+		if s.w != nil {
+			status[i].Pid = s.w.pid()
+			status[i].Uptime = time.Since(s.w.start)
+		}
+	}
+
+	return status
+}
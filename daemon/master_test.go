@@ -0,0 +1,231 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMaster(t *testing.T) {
+	at := assert.New(t)
+	logger := log.New(ioutil.Discard, "", 0)
+
+	t.Run("default", func(t *testing.T) {
+		m, err := newMaster(logger)
+		require.Nil(t, err)
+		at.Len(m.slots, 1)
+	})
+
+	t.Run("default with listener", func(t *testing.T) {
+		config.Set("daemon.listen", ":3000")
+		defer config.Set("daemon.listen", nil)
+
+		m, err := newMaster(logger)
+		require.Nil(t, err)
+		at.Len(m.slots, runtime.NumCPU())
+	})
+
+	t.Run("workers", func(t *testing.T) {
+		config.Set("daemon.workers", 2)
+		defer config.Set("daemon.workers", nil)
+
+		m, err := newMaster(logger)
+		require.Nil(t, err)
+		at.Len(m.slots, 2)
+		at.NotSame(m.slots[0].policy, m.slots[1].policy)
+	})
+
+	t.Run("policy error", func(t *testing.T) {
+		config.Set("daemon.backoffJitter", -1)
+		defer config.Set("daemon.backoffJitter", nil)
+
+		_, err := newMaster(logger)
+		at.NotNil(err)
+	})
+}
+
+func TestMaster(t *testing.T) {
+	at := assert.New(t)
+
+	config.Set("daemon.workers", 2)
+	defer config.Set("daemon.workers", nil)
+
+	t.Run("status", func(t *testing.T) {
+		m, result := runTestMaster(t, "sleep")
+
+		status := m.status()
+		require.Len(t, status, 2)
+		for i, s := range status {
+			at.Equal(i, s.ID)
+			at.NotZero(s.Pid)
+			at.True(s.Uptime > 0)
+			at.Zero(s.Restarts)
+		}
+
+		sigCh <- syscall.SIGTERM
+
+		at.Equal(0, <-result)
+		for _, s := range m.status() {
+			at.Zero(s.Pid)
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		config.Set("daemon.noRestartExitCodes", []int{3})
+		defer config.Set("daemon.noRestartExitCodes", nil)
+
+		_, result := runTestMaster(t, "sleep")
+
+		sigCh <- syscall.SIGHUP
+
+		at.Equal(3, <-result)
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		config.Set("daemon.stopTimeout", "100ms")
+		defer config.Set("daemon.stopTimeout", nil)
+
+		_, result := runTestMaster(t, "ignore")
+
+		start := time.Now()
+		sigCh <- syscall.SIGTERM
+
+		at.Equal(0, <-result)
+		at.Less(int64(time.Since(start)), int64(time.Second*5))
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		config.Set("daemon.tries", 3)
+		defer config.Set("daemon.tries", nil)
+		config.Set("daemon.backoffBase", "1ms")
+		defer config.Set("daemon.backoffBase", nil)
+
+		deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "fail"})
+		defer deck.TeardownEnvs()
+		deck.SetupCmd()
+		defer deck.TeardownCmd()
+
+		m, err := newMaster(log.New(ioutil.Discard, "", 0))
+		require.Nil(t, err)
+
+		// every worker is restarted individually until
+		// one of them gives up and the others are stopped
+		at.Equal(1, m.run())
+
+		restarts := 0
+		for _, s := range m.status() {
+			at.Zero(s.Pid)
+			restarts += s.Restarts
+		}
+		at.GreaterOrEqual(restarts, 2)
+	})
+
+	t.Run("upgrade", func(t *testing.T) {
+		m, result := runTestMaster(t, "sleep")
+		old := m.workers()
+
+		sigCh <- syscall.SIGUSR2
+
+		for _, w := range old {
+			<-w.done
+		}
+		for i, s := range m.status() {
+			at.NotZero(s.Pid)
+			at.NotEqual(old[i].pid(), s.Pid)
+		}
+
+		sigCh <- syscall.SIGTERM
+
+		at.Equal(0, <-result)
+	})
+
+	t.Run("upgrade with old workers exiting at once", func(t *testing.T) {
+		// old workers exit with 0 on SIGTERM, which isn't restarted
+		m, result := runTestMaster(t, "term")
+		old := m.workers()
+
+		sigCh <- syscall.SIGUSR2
+
+		for _, w := range old {
+			<-w.done
+		}
+		for i, s := range m.status() {
+			at.NotZero(s.Pid)
+			at.NotEqual(old[i].pid(), s.Pid)
+			at.Zero(s.Restarts)
+		}
+
+		select {
+		case code := <-result:
+			require.FailNow(t, "master exited", "code %d", code)
+		case <-time.After(time.Millisecond * 100):
+		}
+
+		sigCh <- syscall.SIGTERM
+
+		at.Equal(0, <-result)
+	})
+
+	t.Run("stop while upgrading", func(t *testing.T) {
+		_, result := runTestMaster(t, "sleep")
+
+		// new workers never get ready
+		require.Nil(t, os.Setenv(envTestWorker, "hang"))
+
+		sigCh <- syscall.SIGUSR2
+		// ignored while upgrading
+		sigCh <- syscall.SIGUSR2
+
+		start := time.Now()
+		sigCh <- syscall.SIGTERM
+
+		at.Equal(0, <-result)
+		at.Less(int64(time.Since(start)), int64(time.Second*5))
+	})
+}
+
+// runTestMaster runs a master with helper workers in the mode, and
+// returns once all of them are ready. The exit code is sent to result.
+func runTestMaster(t *testing.T, mode string) (*master, <-chan int) {
+	deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: mode})
+	deck.SetupCmd()
+	t.Cleanup(func() {
+		deck.TeardownCmd()
+		deck.TeardownEnvs()
+	})
+
+	m, err := newMaster(log.New(ioutil.Discard, "", 0))
+	require.Nil(t, err)
+
+	result := make(chan int, 1)
+	go func() { result <- m.run() }()
+
+	for deadline := time.Now().Add(time.Second * 5); ; {
+		ws := m.workers()
+		if len(ws) == len(m.slots) {
+			for _, w := range ws {
+				select {
+				case <-w.ready:
+				case <-w.done:
+					require.FailNow(t, "helper exited", "%v", w.err)
+				}
+			}
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "workers not started")
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	return m, result
+}
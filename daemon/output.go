@@ -0,0 +1,265 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+)
+
+// Streams of lines written by the master
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+	streamEvent  = "event"
+	streamMaster = "master"
+)
+
+// logOutput is the output of the master set up in run. Output of
+// workers is captured only if it's set.
+var logOutput *output
+
+// drainTimeout limits the time of reading remaining output after a
+// worker exits, since its children may hold the pipes
+var drainTimeout = time.Second
+
+// timeFormat is the same as log.LstdFlags
+const timeFormat = "2006/01/02 15:04:05"
+
+// output writes lines of workers, events of workers and messages
+// of the master. Output of workers goes to [Daemon] StdoutLogFile
+// and StderrLogFile, while events and messages go to the latter.
+type output struct {
+	mu     sync.Mutex
+	json   bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// outputLine is a line of output in json format
+type outputLine struct {
+	Time    string `json:"time"`
+	Pid     int    `json:"pid"`
+	Worker  *int   `json:"worker,omitempty"`
+	Stream  string `json:"stream"`
+	Msg     string `json:"msg,omitempty"`
+	Event   string `json:"event,omitempty"`
+	Code    *int   `json:"code,omitempty"`
+	Signal  string `json:"signal,omitempty"`
+	Runtime string `json:"runtime,omitempty"`
+}
+
+// newOutput creates the output of the master by config:
+//  [Daemon]
+//  # text or json
+//  LogFormat = "text"
+// A text line looks like
+//  2021/03/01 12:00:00 [pid:123 stdout] hello
+// and a json line looks like
+//  {"time":"2021-03-01T12:00:00Z","pid":123,"worker":0,"stream":"stdout","msg":"hello"}
+// The output is usable in text format on error.
+func newOutput() (*output, error) {
+	o := &output{stdout: os.Stdout, stderr: os.Stderr}
+
+	if stdoutLogFile != nil {
+		o.stdout = stdoutLogFile
+	}
+
+	if stderrLogFile != nil {
+		o.stderr = stderrLogFile
+	}
+
+	switch f := config.GetString("daemon.logFormat", "text"); f {
+	case "text":
+	case "json":
+		o.json = true
+	default:
+		return o, fmt.Errorf("dawn: invalid log format %s, should be text or json", f)
+	}
+
+	return o, nil
+}
+
+// logger returns a logger of the master writing to the output
+func (o *output) logger() *log.Logger {
+	if o.json {
+		return log.New(masterWriter{o}, "", 0)
+	}
+	return log.New(masterWriter{o}, "", log.LstdFlags)
+}
+
+// masterWriter writes messages of the master
+type masterWriter struct {
+	o *output
+}
+
+func (w masterWriter) Write(p []byte) (int, error) {
+	if !w.o.json {
+		w.o.mu.Lock()
+		defer w.o.mu.Unlock()
+		return w.o.stderr.Write(p)
+	}
+
+	msg := string(bytes.TrimSuffix(p, []byte{'\n'}))
+	w.o.write(w.o.stderr, outputLine{Pid: os.Getpid(), Stream: streamMaster, Msg: msg})
+
+	return len(p), nil
+}
+
+// line writes a line of the worker from the stream
+func (o *output) line(w *worker, stream, msg string) {
+	dst := o.stderr
+	if stream == streamStdout {
+		dst = o.stdout
+	}
+
+	if o.json {
+		o.write(dst, outputLine{Pid: w.pid(), Worker: &w.id, Stream: stream, Msg: msg})
+		return
+	}
+
+	o.writeText(dst, fmt.Sprintf("[pid:%d %s] %s", w.pid(), stream, msg))
+}
+
+// spawned records the spawn event of the worker
+func (o *output) spawned(w *worker) {
+	if o.json {
+		o.write(o.stderr, outputLine{Pid: w.pid(), Worker: &w.id, Stream: streamEvent, Event: "spawn"})
+		return
+	}
+
+	o.writeText(o.stderr, fmt.Sprintf("[pid:%d event] spawn worker #%d", w.pid(), w.id))
+}
+
+// exited records the exit event of the worker with exit code,
+// signal and runtime
+func (o *output) exited(w *worker) {
+	var (
+		code    = -1
+		sig     string
+		runtime = time.Since(w.start).Round(time.Millisecond)
+	)
+
+	if state := w.cmd.ProcessState; state != nil {
+		code, sig = state.ExitCode(), exitSignal(state)
+	}
+
+	if o.json {
+		o.write(o.stderr, outputLine{Pid: w.pid(), Worker: &w.id, Stream: streamEvent, Event: "exit",
+			Code: &code, Signal: sig, Runtime: runtime.String()})
+		return
+	}
+
+	if sig == "" {
+		sig = "none"
+	}
+
+	o.writeText(o.stderr, fmt.Sprintf("[pid:%d event] exit worker #%d, code: %d, signal: %s, runtime: %s",
+		w.pid(), w.id, code, sig, runtime))
+}
+
+func (o *output) writeText(dst io.Writer, s string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, _ = io.WriteString(dst, time.Now().Format(timeFormat)+" "+s+"\n")
+}
+
+func (o *output) write(dst io.Writer, l outputLine) {
+	l.Time = time.Now().Format(time.RFC3339Nano)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// keep messages readable
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(l); err != nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, _ = dst.Write(buf.Bytes())
+}
+
+// capture is the pipes of stdout and stderr of a worker
+type capture struct {
+	stdoutR, stdoutW *os.File
+	stderrR, stderrW *os.File
+	wg               sync.WaitGroup
+}
+
+func newCapture() (c *capture, err error) {
+	c = &capture{}
+
+	if c.stdoutR, c.stdoutW, err = os.Pipe(); err != nil {
+		return nil, err
+	}
+
+	if c.stderrR, c.stderrW, err = os.Pipe(); err != nil {
+		c.closeWriters()
+		_ = c.stdoutR.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// closeWriters closes write ends after the worker holds its own copies
+func (c *capture) closeWriters() {
+	_ = c.stdoutW.Close()
+	if c.stderrW != nil {
+		_ = c.stderrW.Close()
+	}
+}
+
+// closeReaders closes read ends if the worker fails to start
+func (c *capture) closeReaders() {
+	_ = c.stdoutR.Close()
+	_ = c.stderrR.Close()
+}
+
+// copy writes lines of the worker to the output until EOF
+func (c *capture) copy(o *output, w *worker) {
+	c.wg.Add(2)
+	go c.copyLines(o, w, c.stdoutR, streamStdout)
+	go c.copyLines(o, w, c.stderrR, streamStderr)
+}
+
+func (c *capture) copyLines(o *output, w *worker, r *os.File, stream string) {
+	defer c.wg.Done()
+	defer func() { _ = r.Close() }()
+
+	br := bufio.NewReader(r)
+	for {
+		// a too long line is split into multiple lines
+		line, _, err := br.ReadLine()
+		if len(line) > 0 || err == nil {
+			o.line(w, stream, string(line))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wait waits for remaining output within drainTimeout
+func (c *capture) wait() {
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(drainTimeout):
+	}
+}
@@ -0,0 +1,168 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestNewOutput(t *testing.T) {
+	at := assert.New(t)
+
+	t.Run("default", func(t *testing.T) {
+		o, err := newOutput()
+		require.Nil(t, err)
+		at.False(o.json)
+		at.Equal(os.Stdout, o.stdout)
+		at.Equal(os.Stderr, o.stderr)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		config.Set("daemon.logFormat", "json")
+		defer config.Set("daemon.logFormat", nil)
+
+		stdout, stderr := nopCloser{&bytes.Buffer{}}, nopCloser{&bytes.Buffer{}}
+		stdoutLogFile, stderrLogFile = stdout, stderr
+		defer func() { stdoutLogFile, stderrLogFile = nil, nil }()
+
+		o, err := newOutput()
+		require.Nil(t, err)
+		at.True(o.json)
+		at.Equal(stdout, o.stdout)
+		at.Equal(stderr, o.stderr)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		config.Set("daemon.logFormat", "xml")
+		defer config.Set("daemon.logFormat", nil)
+
+		o, err := newOutput()
+		at.NotNil(err)
+		at.NotNil(o)
+	})
+}
+
+func TestOutput(t *testing.T) {
+	at := assert.New(t)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	w := exitedWorker(t)
+
+	t.Run("text", func(t *testing.T) {
+		stdout.Reset()
+		stderr.Reset()
+		o := &output{stdout: stdout, stderr: stderr}
+
+		o.logger().Print("dawn: message")
+		o.line(w, streamStdout, "hello")
+		o.line(w, streamStderr, "oops")
+		o.spawned(w)
+		o.exited(w)
+
+		pid := strconv.Itoa(w.pid())
+		at.Equal("[pid:"+pid+" stdout] hello\n", stdout.String()[len(timeFormat)+1:])
+
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		require.Len(t, lines, 4)
+		at.True(strings.HasSuffix(lines[0], " dawn: message"))
+		at.True(strings.HasSuffix(lines[1], " [pid:"+pid+" stderr] oops"))
+		at.True(strings.HasSuffix(lines[2], " [pid:"+pid+" event] spawn worker #1"))
+		at.Contains(lines[3], " [pid:"+pid+" event] exit worker #1, code: 3, signal: none, runtime: ")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		stdout.Reset()
+		stderr.Reset()
+		o := &output{stdout: stdout, stderr: stderr, json: true}
+
+		o.logger().Print("dawn: <message>")
+		o.line(w, streamStdout, "hello")
+		o.exited(w)
+
+		at.Contains(stderr.String(), `"msg":"dawn: <message>"`)
+
+		var l outputLine
+		require.Nil(t, json.Unmarshal(stdout.Bytes(), &l))
+		at.Equal(w.pid(), l.Pid)
+		at.Equal(1, *l.Worker)
+		at.Equal(streamStdout, l.Stream)
+		at.Equal("hello", l.Msg)
+		_, err := time.Parse(time.RFC3339Nano, l.Time)
+		at.Nil(err)
+
+		dec := json.NewDecoder(stderr)
+
+		l = outputLine{}
+		require.Nil(t, dec.Decode(&l))
+		at.Equal(os.Getpid(), l.Pid)
+		at.Nil(l.Worker)
+		at.Equal(streamMaster, l.Stream)
+		at.Equal("dawn: <message>", l.Msg)
+
+		l = outputLine{}
+		require.Nil(t, dec.Decode(&l))
+		at.Equal(streamEvent, l.Stream)
+		at.Equal("exit", l.Event)
+		at.Equal(3, *l.Code)
+		at.NotEmpty(l.Runtime)
+	})
+}
+
+func TestCaptureOutput(t *testing.T) {
+	at := assert.New(t)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	logOutput = &output{stdout: stdout, stderr: stderr}
+	defer func() { logOutput = nil }()
+
+	deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "echo"})
+	defer deck.TeardownEnvs()
+	deck.SetupCmd()
+	defer deck.TeardownCmd()
+
+	w, err := startWorker(0)
+	require.Nil(t, err)
+	<-w.done
+
+	pid := strconv.Itoa(w.pid())
+	at.Contains(stdout.String(), " [pid:"+pid+" stdout] hello\n")
+	at.Contains(stderr.String(), " [pid:"+pid+" stderr] oops\n")
+	at.Contains(stderr.String(), " [pid:"+pid+" event] spawn worker #0\n")
+	at.Contains(stderr.String(), " [pid:"+pid+" event] exit worker #0, code: 0")
+
+	t.Run("spawn error", func(t *testing.T) {
+		deck.SetupCmdError()
+		defer deck.TeardownCmd()
+
+		_, err := startWorker(0)
+		at.NotNil(err)
+	})
+}
+
+// exitedWorker returns a worker with id 1 exited with code 3
+func exitedWorker(t *testing.T) *worker {
+	deck.SetupCmd()
+	defer deck.TeardownCmd()
+
+	cmd := execCommand("helper")
+	cmd.Env = append(cmd.Env, envTestWorker+"=fail")
+	_ = cmd.Run()
+	require.NotNil(t, cmd.ProcessState)
+
+	return &worker{id: 1, cmd: cmd, start: time.Now()}
+}
@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-dawn/dawn/config"
+)
+
+// pidFile is the locked file holding the pid of the master process
+type pidFile struct {
+	f    *os.File
+	name string
+}
+
+// pidFilePath returns the path of pid file by config:
+//  [Daemon]
+//  PidFile = "dawn.pid"
+func pidFilePath() string {
+	return config.GetString("daemon.pidFile", "dawn.pid")
+}
+
+// createPidFile locks the pid file and writes the current pid to
+// it. The lock is held until the process exits, so another master
+// can't be started with the same pid file.
+func createPidFile(name string) (*pidFile, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, fmt.Errorf("dawn: failed to create directory of pid file %s: %s", name, err)
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("dawn: failed to open pid file %s: %s", name, err)
+	}
+
+	if err = lockFile(f); err != nil {
+		_ = f.Close()
+		pid, _ := readPid(name)
+		return nil, fmt.Errorf("%w (pid %d)", ErrRunning, pid)
+	}
+
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	}
+
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("dawn: failed to write pid file %s: %s", name, err)
+	}
+
+	return &pidFile{f: f, name: name}, nil
+}
+
+// remove removes the pid file before releasing the lock
+func (p *pidFile) remove() {
+	_ = os.Remove(p.name)
+	_ = p.f.Close()
+}
+
+func readPid(name string) (int, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("dawn: invalid pid file %s: %s", name, err)
+	}
+
+	return pid, nil
+}
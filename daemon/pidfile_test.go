@@ -0,0 +1,61 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPidFile(t *testing.T) {
+	at := assert.New(t)
+
+	name := filepath.Join(tempDir(t), "run", "dawn.pid")
+
+	pf, err := createPidFile(name)
+	at.Nil(err)
+
+	pid, err := readPid(name)
+	at.Nil(err)
+	at.Equal(os.Getpid(), pid)
+
+	_, err = createPidFile(name)
+	at.True(errors.Is(err, ErrRunning))
+
+	pf.remove()
+
+	_, err = os.Stat(name)
+	at.True(os.IsNotExist(err))
+}
+
+func TestPidFileError(t *testing.T) {
+	at := assert.New(t)
+
+	dir := tempDir(t)
+
+	t.Run("directory", func(t *testing.T) {
+		f := filepath.Join(dir, "file")
+		at.Nil(ioutil.WriteFile(f, nil, 0644))
+
+		_, err := createPidFile(filepath.Join(f, "dawn.pid"))
+		at.NotNil(err)
+	})
+
+	t.Run("open", func(t *testing.T) {
+		_, err := createPidFile(dir)
+		at.NotNil(err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		f := filepath.Join(dir, "invalid.pid")
+		at.Nil(ioutil.WriteFile(f, []byte("pid"), 0644))
+
+		_, err := readPid(f)
+		at.NotNil(err)
+	})
+}
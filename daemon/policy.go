@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+)
+
+// restartPolicy decides whether and when to restart a worker
+type restartPolicy struct {
+	tries   int
+	window  time.Duration
+	base    time.Duration
+	max     time.Duration
+	jitter  float64
+	noCodes map[int]bool
+
+	// failures are times of failures within the window
+	failures []time.Time
+}
+
+// newRestartPolicy creates a restart policy by config:
+//  [Daemon]
+//  # give up after so many failures within the FailureWindow
+//  Tries = 10
+//  FailureWindow = "1m"
+//  # restart delay doubles from BackoffBase up to BackoffMax
+//  # on consecutive failures and varies randomly by BackoffJitter
+//  BackoffBase = "100ms"
+//  BackoffMax = "30s"
+//  BackoffJitter = 0.1
+//  # the master exits with the same code instead of restarting
+//  # the worker if it exits with one of these codes
+//  NoRestartExitCodes = [0]
+//  # command run when giving up, see OnGiveUp
+//  GiveUpCommand = []
+func newRestartPolicy() (*restartPolicy, error) {
+	p := &restartPolicy{
+		tries:   config.GetInt("daemon.tries", 10),
+		window:  config.GetDuration("daemon.failureWindow", time.Minute),
+		base:    config.GetDuration("daemon.backoffBase", time.Millisecond*100),
+		max:     config.GetDuration("daemon.backoffMax", time.Second*30),
+		jitter:  config.GetFloat64("daemon.backoffJitter", 0.1),
+		noCodes: make(map[int]bool),
+	}
+
+	if p.jitter < 0 || p.jitter > 1 {
+		return nil, fmt.Errorf("dawn: invalid backoff jitter %v, should be in [0, 1]", p.jitter)
+	}
+
+	codes := reflect.ValueOf(config.Get("daemon.noRestartExitCodes", []int{0}))
+	if codes.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("dawn: invalid exit codes %v", codes)
+	}
+
+	for i := 0; i < codes.Len(); i++ {
+		s := fmt.Sprint(codes.Index(i))
+		code, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("dawn: invalid exit code %s: %s", s, err)
+		}
+		p.noCodes[code] = true
+	}
+
+	return p, nil
+}
+
+// restartable reports whether the worker should be restarted
+// after exiting with the state
+func (p *restartPolicy) restartable(state *os.ProcessState) bool {
+	// a worker killed by signal has code -1
+	return state == nil || !p.noCodes[state.ExitCode()]
+}
+
+// fail records a failure at now and reports whether to give up
+func (p *restartPolicy) fail(now time.Time) (giveUp bool) {
+	kept := p.failures[:0]
+	for _, t := range p.failures {
+		if now.Sub(t) < p.window {
+			kept = append(kept, t)
+		}
+	}
+	p.failures = append(kept, now)
+
+	return len(p.failures) >= p.tries
+}
+
+// backoff returns the delay before restarting, which grows
+// with failures within the window
+func (p *restartPolicy) backoff() time.Duration {
+	d := p.base
+	for i := 1; i < len(p.failures) && d < p.max; i++ {
+		d *= 2
+	}
+	if d > p.max {
+		d = p.max
+	}
+
+	if p.jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.jitter * float64(d))
+	}
+
+	return d
+}
+
+var giveUpHandler func(failures int, err error)
+
+// OnGiveUp sets the callback called in the master process when it
+// gives up restarting the worker, with the number of failures and
+// the last error. It must be set before Run.
+func OnGiveUp(fn func(failures int, err error)) {
+	giveUpHandler = fn
+}
+
+// giveUp calls the callback and runs [Daemon] GiveUpCommand with
+// DAWN_DAEMON_FAILURES and DAWN_DAEMON_ERROR in env
+func giveUp(failures int, err error) error {
+	if giveUpHandler != nil {
+		giveUpHandler(failures, err)
+	}
+
+	args := config.GetStringSlice("daemon.giveUpCommand")
+	if len(args) == 0 {
+		return nil
+	}
+
+	cmd := execCommand(args[0], args[1:]...)
+	cmd.Env = append(cmd.Env, os.Environ()...)
+	cmd.Env = append(cmd.Env, "DAWN_DAEMON_FAILURES="+strconv.Itoa(failures), fmt.Sprintf("DAWN_DAEMON_ERROR=%v", err))
+	cmd.Stdout, cmd.Stderr = stdoutLogFile, stderrLogFile
+
+	if e := cmd.Run(); e != nil {
+		return fmt.Errorf("dawn: failed to run give up command %v: %s", args, e)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRestartPolicy(t *testing.T) {
+	at := assert.New(t)
+
+	t.Run("default", func(t *testing.T) {
+		p, err := newRestartPolicy()
+		require.Nil(t, err)
+
+		at.Equal(10, p.tries)
+		at.Equal(time.Minute, p.window)
+		at.Equal(time.Millisecond*100, p.base)
+		at.Equal(time.Second*30, p.max)
+		at.Equal(0.1, p.jitter)
+		at.Equal(map[int]bool{0: true}, p.noCodes)
+	})
+
+	t.Run("jitter error", func(t *testing.T) {
+		config.Set("daemon.backoffJitter", 2)
+		defer config.Set("daemon.backoffJitter", nil)
+
+		_, err := newRestartPolicy()
+		at.NotNil(err)
+	})
+
+	t.Run("exit codes", func(t *testing.T) {
+		config.Set("daemon.noRestartExitCodes", []interface{}{int64(0), "78"})
+		defer config.Set("daemon.noRestartExitCodes", nil)
+
+		p, err := newRestartPolicy()
+		require.Nil(t, err)
+		at.Equal(map[int]bool{0: true, 78: true}, p.noCodes)
+	})
+
+	t.Run("exit code error", func(t *testing.T) {
+		config.Set("daemon.noRestartExitCodes", []string{"a"})
+		defer config.Set("daemon.noRestartExitCodes", nil)
+
+		_, err := newRestartPolicy()
+		at.NotNil(err)
+
+		config.Set("daemon.noRestartExitCodes", 0)
+
+		_, err = newRestartPolicy()
+		at.NotNil(err)
+	})
+}
+
+func TestRestartPolicy(t *testing.T) {
+	at := assert.New(t)
+
+	t.Run("restartable", func(t *testing.T) {
+		p := &restartPolicy{noCodes: map[int]bool{0: true}}
+
+		at.True(p.restartable(nil))
+		at.False(p.restartable(runHelper(t, "")))
+		at.True(p.restartable(runHelper(t, "fail")))
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		p := &restartPolicy{tries: 3, window: time.Minute}
+		now := time.Now()
+
+		at.False(p.fail(now))
+		at.False(p.fail(now.Add(time.Second * 10)))
+		// failures out of the window are dropped
+		at.False(p.fail(now.Add(time.Minute * 2)))
+		at.Len(p.failures, 1)
+		at.False(p.fail(now.Add(time.Minute * 2)))
+		at.True(p.fail(now.Add(time.Minute * 2)))
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		p := &restartPolicy{base: time.Millisecond * 100, max: time.Second}
+
+		p.failures = make([]time.Time, 1)
+		at.Equal(time.Millisecond*100, p.backoff())
+
+		p.failures = make([]time.Time, 3)
+		at.Equal(time.Millisecond*400, p.backoff())
+
+		p.failures = make([]time.Time, 100)
+		at.Equal(time.Second, p.backoff())
+
+		p.jitter = 0.5
+		for i := 0; i < 10; i++ {
+			d := p.backoff()
+			at.True(d >= time.Millisecond*500 && d <= time.Millisecond*1500, d)
+		}
+	})
+}
+
+func TestGiveUp(t *testing.T) {
+	at := assert.New(t)
+
+	var (
+		failures int
+		lastErr  error
+	)
+	OnGiveUp(func(n int, err error) {
+		failures, lastErr = n, err
+	})
+	defer OnGiveUp(nil)
+
+	e := errors.New("error")
+
+	t.Run("callback", func(t *testing.T) {
+		at.Nil(giveUp(3, e))
+		at.Equal(3, failures)
+		at.Equal(e, lastErr)
+	})
+
+	t.Run("command", func(t *testing.T) {
+		config.Set("daemon.giveUpCommand", []string{"notify", "dawn"})
+		defer config.Set("daemon.giveUpCommand", nil)
+
+		deck.SetupCmd()
+		defer deck.TeardownCmd()
+
+		at.Nil(giveUp(3, e))
+	})
+
+	t.Run("command error", func(t *testing.T) {
+		config.Set("daemon.giveUpCommand", []string{"notify"})
+		defer config.Set("daemon.giveUpCommand", nil)
+
+		deck.SetupCmdError()
+		defer deck.TeardownCmd()
+
+		at.NotNil(giveUp(3, e))
+	})
+
+	t.Run("run", func(t *testing.T) {
+		config.Set("daemon.tries", 2)
+		defer config.Set("daemon.tries", nil)
+		config.Set("daemon.backoffBase", "1ms")
+		defer config.Set("daemon.backoffBase", nil)
+
+		deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "fail"})
+		defer deck.TeardownEnvs()
+		deck.SetupCmd()
+		defer deck.TeardownCmd()
+
+		at.Equal(1, run())
+		at.Equal(2, failures)
+		at.NotNil(lastErr)
+	})
+}
+
+func TestRunExitCode(t *testing.T) {
+	config.Set("daemon.noRestartExitCodes", []int{3})
+	defer config.Set("daemon.noRestartExitCodes", nil)
+
+	deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "fail"})
+	defer deck.TeardownEnvs()
+	deck.SetupCmd()
+	defer deck.TeardownCmd()
+
+	assert.Equal(t, 3, run())
+}
+
+func TestRunPolicyError(t *testing.T) {
+	config.Set("daemon.backoffJitter", -1)
+	defer config.Set("daemon.backoffJitter", nil)
+
+	assert.Equal(t, 1, run())
+}
+
+// runHelper runs a helper command in the mode and returns its state
+func runHelper(t *testing.T, mode string) *os.ProcessState {
+	deck.SetupCmd()
+	defer deck.TeardownCmd()
+
+	cmd := execCommand("helper")
+	cmd.Env = append(cmd.Env, envTestWorker+"="+mode)
+	cmd.Stderr = ioutil.Discard
+	_ = cmd.Run()
+
+	require.NotNil(t, cmd.ProcessState)
+
+	return cmd.ProcessState
+}
+
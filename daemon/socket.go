@@ -0,0 +1,270 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	dawnlog "github.com/go-dawn/dawn/log"
+)
+
+// Commands answered by the master via the control socket
+const (
+	// CommandStatus returns MasterStatus
+	CommandStatus = "status"
+	// CommandWorkers returns status of all workers
+	CommandWorkers = "workers"
+	// CommandRestart restarts the worker with the id
+	CommandRestart = "restart"
+	// CommandReopen reopens log files of the master
+	CommandReopen = "reopen"
+	// CommandShutdown stops workers and then the master
+	CommandShutdown = "shutdown"
+)
+
+var controlListener net.Listener
+
+// controlTimeout limits the time of handling a connection
+var controlTimeout = time.Second * 10
+
+// MasterStatus is the status of the master reported via the
+// control socket
+type MasterStatus struct {
+	Pid     int            `json:"pid"`
+	Uptime  time.Duration  `json:"uptime"`
+	Workers []WorkerStatus `json:"workers"`
+}
+
+// ControlRequest is a json command sent to the control socket
+type ControlRequest struct {
+	Command string `json:"command"`
+	// Worker is the worker id for CommandRestart
+	Worker int `json:"worker"`
+}
+
+// ControlResponse is the json result of a ControlRequest
+type ControlResponse struct {
+	Error   string         `json:"error,omitempty"`
+	Status  *MasterStatus  `json:"status,omitempty"`
+	Workers []WorkerStatus `json:"workers,omitempty"`
+}
+
+// controlCall is a request handled in the loop of the master
+type controlCall struct {
+	req   ControlRequest
+	reply chan ControlResponse
+}
+
+// controlSocketPath returns the path of control socket by config,
+// which defaults to the pid file with .sock extension:
+//  [Daemon]
+//  ControlSocket = "dawn.sock"
+func controlSocketPath() string {
+	pid := pidFilePath()
+	def := strings.TrimSuffix(pid, filepath.Ext(pid)) + ".sock"
+	return config.GetString("daemon.controlSocket", def)
+}
+
+// setupControlSocket listens on the control socket. A stale socket
+// file is removed since the pid file is locked by current master.
+// Commands aren't authenticated, so only the owner can connect.
+func setupControlSocket() (err error) {
+	name := controlSocketPath()
+	if name == "" {
+		return nil
+	}
+
+	if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("dawn: failed to remove control socket %s: %s", name, err)
+	}
+
+	if controlListener, err = net.Listen("unix", name); err != nil {
+		return fmt.Errorf("dawn: failed to listen on control socket %s: %s", name, err)
+	}
+
+	if err = os.Chmod(name, 0600); err != nil {
+		teardownControlSocket()
+		return fmt.Errorf("dawn: failed to chmod control socket %s: %s", name, err)
+	}
+
+	return nil
+}
+
+func teardownControlSocket() {
+	if controlListener != nil {
+		// the socket file is removed as well
+		_ = controlListener.Close()
+		controlListener = nil
+	}
+}
+
+// serve handles connections of the control socket until ln is closed
+func (m *master) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go m.handle(conn)
+	}
+}
+
+// handle reads one request from conn and writes the response
+func (m *master) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	var (
+		req  ControlRequest
+		resp ControlResponse
+	)
+
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		resp.Error = fmt.Sprintf("dawn: invalid control request: %s", err)
+	} else {
+		resp = m.dispatch(req)
+	}
+
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// dispatch answers queries directly and passes commands changing
+// workers to the loop of the master
+func (m *master) dispatch(req ControlRequest) (resp ControlResponse) {
+	switch req.Command {
+	case CommandStatus:
+		resp.Status = &MasterStatus{
+			Pid:     os.Getpid(),
+			Uptime:  time.Since(m.started),
+			Workers: m.status(),
+		}
+	case CommandWorkers:
+		resp.Workers = m.status()
+	case CommandReopen:
+		if err := dawnlog.ReopenFiles(); err != nil {
+			resp.Error = fmt.Sprintf("dawn: failed to reopen log files: %s", err)
+		}
+	case CommandRestart, CommandShutdown:
+		c := &controlCall{req: req, reply: make(chan ControlResponse, 1)}
+		select {
+		case m.calls <- c:
+			resp = <-c.reply
+		case <-m.quit:
+			resp.Error = ErrNotRunning.Error()
+		}
+	default:
+		resp.Error = fmt.Sprintf("dawn: unknown control command %q", req.Command)
+	}
+
+	return
+}
+
+// call handles the control call in the loop of the master
+func (m *master) call(c *controlCall) {
+	var resp ControlResponse
+
+	switch c.req.Command {
+	case CommandRestart:
+		if err := m.restart(c.req.Worker); err != nil {
+			resp.Error = err.Error()
+		}
+	case CommandShutdown:
+		m.logger.Print("dawn: shutdown by control socket, stopping workers")
+		m.stop(syscall.SIGTERM)
+	}
+
+	c.reply <- resp
+}
+
+// Client talks to the master process via the control socket
+type Client struct {
+	// Addr is the path of the control socket
+	Addr string
+	// Timeout limits the time of a request
+	Timeout time.Duration
+}
+
+// NewClient returns a client of the control socket by config,
+// see [Daemon] ControlSocket.
+func NewClient() *Client {
+	return &Client{Addr: controlSocketPath(), Timeout: controlTimeout}
+}
+
+// Status returns the status of the master and its workers
+func (c *Client) Status() (*MasterStatus, error) {
+	resp, err := c.Do(ControlRequest{Command: CommandStatus})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Status, nil
+}
+
+// Workers returns the status of workers
+func (c *Client) Workers() ([]WorkerStatus, error) {
+	resp, err := c.Do(ControlRequest{Command: CommandWorkers})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Workers, nil
+}
+
+// RestartWorker stops the worker with the id and starts a new one
+// without counting it as a failure. It returns once the worker is
+// asked to stop.
+func (c *Client) RestartWorker(id int) error {
+	_, err := c.Do(ControlRequest{Command: CommandRestart, Worker: id})
+	return err
+}
+
+// ReopenLogs reopens log files of the master, e.g. after they
+// are moved by logrotate
+func (c *Client) ReopenLogs() error {
+	_, err := c.Do(ControlRequest{Command: CommandReopen})
+	return err
+}
+
+// Shutdown asks the master to stop workers and exit. It returns
+// without waiting for the master to exit.
+func (c *Client) Shutdown() error {
+	_, err := c.Do(ControlRequest{Command: CommandShutdown})
+	return err
+}
+
+// Do sends the request to the master and returns the response.
+// The error in response is returned as err.
+func (c *Client) Do(req ControlRequest) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", c.Addr, c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("dawn: failed to connect to control socket %s: %s", c.Addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if c.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("dawn: failed to send control request: %s", err)
+	}
+
+	resp := &ControlResponse{}
+	if err = json.NewDecoder(conn).Decode(resp); err != nil {
+		return nil, fmt.Errorf("dawn: failed to read control response: %s", err)
+	}
+
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,207 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlSocketPath(t *testing.T) {
+	at := assert.New(t)
+
+	at.Equal("dawn.sock", controlSocketPath())
+
+	config.Set("daemon.pidFile", "run/app.pid")
+	defer config.Set("daemon.pidFile", nil)
+	at.Equal("run/app.sock", controlSocketPath())
+
+	config.Set("daemon.controlSocket", "ctl.sock")
+	defer config.Set("daemon.controlSocket", nil)
+	at.Equal("ctl.sock", controlSocketPath())
+}
+
+func TestSetupControlSocket(t *testing.T) {
+	at := assert.New(t)
+
+	name := filepath.Join(tempDir(t), "dawn.sock")
+	config.Set("daemon.controlSocket", name)
+	defer config.Set("daemon.controlSocket", nil)
+
+	t.Run("stale", func(t *testing.T) {
+		require.Nil(t, ioutil.WriteFile(name, nil, 0644))
+
+		at.Nil(setupControlSocket())
+		at.NotNil(controlListener)
+
+		fi, err := os.Stat(name)
+		require.Nil(t, err)
+		at.Equal(os.FileMode(0600), fi.Mode().Perm())
+
+		teardownControlSocket()
+		at.Nil(controlListener)
+
+		_, err = os.Stat(name)
+		at.True(os.IsNotExist(err))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		config.Set("daemon.controlSocket", "")
+
+		at.Nil(setupControlSocket())
+		at.Nil(controlListener)
+	})
+
+	t.Run("remove error", func(t *testing.T) {
+		dir := filepath.Join(tempDir(t), "dir")
+		require.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+		config.Set("daemon.controlSocket", dir)
+
+		at.NotNil(setupControlSocket())
+	})
+
+	t.Run("listen error", func(t *testing.T) {
+		config.Set("daemon.controlSocket", filepath.Join(name, "not", "exist"))
+
+		at.NotNil(setupControlSocket())
+	})
+}
+
+func TestControlSocket(t *testing.T) {
+	at := assert.New(t)
+
+	config.Set("daemon.workers", 2)
+	defer config.Set("daemon.workers", nil)
+	config.Set("daemon.controlSocket", filepath.Join(tempDir(t), "dawn.sock"))
+	defer config.Set("daemon.controlSocket", nil)
+
+	require.Nil(t, setupControlSocket())
+	defer teardownControlSocket()
+
+	m, result := runTestMaster(t, "sleep")
+	c := NewClient()
+
+	t.Run("status", func(t *testing.T) {
+		status, err := c.Status()
+		require.Nil(t, err)
+		at.Equal(os.Getpid(), status.Pid)
+		at.True(status.Uptime > 0)
+		at.Equal(m.status()[1].Pid, status.Workers[1].Pid)
+	})
+
+	t.Run("workers", func(t *testing.T) {
+		workers, err := c.Workers()
+		require.Nil(t, err)
+		at.Len(workers, 2)
+
+		deck.RedirectStdout()
+		printWorkers()
+		out := deck.DumpStdout()
+		at.Equal(2, strings.Count(out, "worker #"))
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		old := m.workers()[0]
+
+		require.Nil(t, c.RestartWorker(0))
+		<-old.done
+
+		restarted := func() bool {
+			pid := m.status()[0].Pid
+			return pid != 0 && pid != old.pid()
+		}
+		for deadline := time.Now().Add(time.Second * 5); !restarted(); {
+			require.True(t, time.Now().Before(deadline), "worker not restarted")
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		// it's not counted as a failure
+		at.Zero(m.status()[0].Restarts)
+
+		at.NotNil(c.RestartWorker(2))
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		at.Nil(c.ReopenLogs())
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		_, err := c.Do(ControlRequest{Command: "unknown"})
+		at.NotNil(err)
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		conn, err := net.Dial("unix", c.Addr)
+		require.Nil(t, err)
+		defer func() { _ = conn.Close() }()
+
+		_, err = conn.Write([]byte("invalid\n"))
+		require.Nil(t, err)
+
+		b, err := ioutil.ReadAll(conn)
+		require.Nil(t, err)
+		at.Contains(string(b), "invalid control request")
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		require.Nil(t, c.Shutdown())
+		at.Equal(0, <-result)
+
+		// the master quits
+		resp := m.dispatch(ControlRequest{Command: CommandShutdown})
+		at.Equal(ErrNotRunning.Error(), resp.Error)
+	})
+}
+
+func TestMasterRestart(t *testing.T) {
+	at := assert.New(t)
+
+	m := &master{slots: []*slot{{}}}
+
+	at.NotNil(m.restart(-1))
+	at.NotNil(m.restart(0))
+
+	m.stopping = true
+	at.NotNil(m.restart(0))
+}
+
+func TestClient(t *testing.T) {
+	at := assert.New(t)
+
+	c := &Client{Addr: filepath.Join(tempDir(t), "dawn.sock")}
+
+	_, err := c.Status()
+	at.NotNil(err)
+
+	_, err = c.Workers()
+	at.NotNil(err)
+
+	at.NotNil(c.ReopenLogs())
+	at.NotNil(c.Shutdown())
+
+	t.Run("invalid response", func(t *testing.T) {
+		ln, err := net.Listen("unix", c.Addr)
+		require.Nil(t, err)
+		defer func() { _ = ln.Close() }()
+
+		go func() {
+			conn, err := ln.Accept()
+			if err == nil {
+				_ = conn.Close()
+			}
+		}()
+
+		_, err = c.Do(ControlRequest{Command: CommandStatus})
+		at.NotNil(err)
+	})
+}
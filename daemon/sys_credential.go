@@ -0,0 +1,63 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+type credential = syscall.Credential
+
+// lookupCredential returns the credential of the user and group by
+// name or id. The gid of the user is used if the group is empty.
+func lookupCredential(usr, grp string) (*credential, error) {
+	if usr == "" && grp == "" {
+		return nil, nil
+	}
+
+	c := &credential{
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+		// only root is able to drop supplementary groups
+		NoSetGroups: os.Getuid() != 0,
+	}
+
+	if usr != "" {
+		u, err := user.Lookup(usr)
+		if err != nil {
+			if u, err = user.LookupId(usr); err != nil {
+				return nil, fmt.Errorf("dawn: unknown user %s: %s", usr, err)
+			}
+		}
+
+		uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+		c.Uid, c.Gid = uint32(uid), uint32(gid)
+	}
+
+	if grp != "" {
+		g, err := user.LookupGroup(grp)
+		if err != nil {
+			if g, err = user.LookupGroupId(grp); err != nil {
+				return nil, fmt.Errorf("dawn: unknown group %s: %s", grp, err)
+			}
+		}
+
+		gid, _ := strconv.ParseUint(g.Gid, 10, 32)
+		c.Gid = uint32(gid)
+	}
+
+	return c, nil
+}
+
+func setCredential(attr *syscall.SysProcAttr, c *credential) {
+	attr.Credential = c
+}
+
+func setUmask(mask int) {
+	syscall.Umask(mask)
+}
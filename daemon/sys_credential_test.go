@@ -0,0 +1,65 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupCredential(t *testing.T) {
+	at := assert.New(t)
+
+	u, err := user.Current()
+	require.Nil(t, err)
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+
+	t.Run("none", func(t *testing.T) {
+		c, err := lookupCredential("", "")
+		at.Nil(err)
+		at.Nil(c)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		for _, name := range []string{u.Username, u.Uid} {
+			c, err := lookupCredential(name, "")
+			require.Nil(t, err)
+			at.Equal(uid, c.Uid)
+			at.Equal(u.Gid, strconv.Itoa(int(c.Gid)))
+			at.Equal(uid != 0, c.NoSetGroups)
+		}
+	})
+
+	t.Run("group", func(t *testing.T) {
+		g, err := user.LookupGroupId(strconv.Itoa(int(gid)))
+		require.Nil(t, err)
+
+		for _, name := range []string{g.Name, g.Gid} {
+			c, err := lookupCredential("", name)
+			require.Nil(t, err)
+			at.Equal(uid, c.Uid)
+			at.Equal(gid, c.Gid)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := lookupCredential("dawn-unknown-user", "")
+		at.NotNil(err)
+
+		_, err = lookupCredential("", "dawn-unknown-group")
+		at.NotNil(err)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		attr := &syscall.SysProcAttr{}
+		c := &credential{Uid: uid}
+		setCredential(attr, c)
+		at.Equal(c, attr.Credential)
+	})
+}
@@ -0,0 +1,25 @@
+// +build windows
+
+package daemon
+
+import (
+	"errors"
+	"syscall"
+)
+
+type credential struct{}
+
+// lookupCredential fails if the user or group is set since
+// they are not supported on windows
+func lookupCredential(usr, grp string) (*credential, error) {
+	if usr != "" || grp != "" {
+		return nil, errors.New("dawn: user and group of workers are not supported on windows")
+	}
+
+	return nil, nil
+}
+
+func setCredential(_ *syscall.SysProcAttr, _ *credential) {}
+
+// setUmask is a no-op since umask is not supported on windows
+func setUmask(_ int) {}
@@ -0,0 +1,34 @@
+// +build windows
+
+package daemon
+
+import (
+	"os"
+)
+
+// lockFile is a no-op since flock is not supported on windows
+func lockFile(_ *os.File) error {
+	return nil
+}
+
+// isLocked reports whether the process in the pid file exists
+func isLocked(name string) (bool, error) {
+	pid, err := readPid(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if _, err = os.FindProcess(pid); err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// terminate kills the process since signals are not supported on windows
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,10 @@
+// +build windows
+
+package daemon
+
+import "syscall"
+
+// reusePort is a no-op since SO_REUSEPORT is not supported on windows
+func reusePort(_, _ string, _ syscall.RawConn) error {
+	return nil
+}
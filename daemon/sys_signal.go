@@ -0,0 +1,30 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// inheritFiles means workers can inherit the ready pipe and listener
+const inheritFiles = true
+
+// masterSignals are handled by the master process
+var masterSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGUSR2}
+
+func isUpgrade(sig os.Signal) bool {
+	return sig == syscall.SIGUSR2
+}
+
+func signalUpgrade(p *os.Process) error {
+	return p.Signal(syscall.SIGUSR2)
+}
+
+// exitSignal returns the signal terminating the process, if any
+func exitSignal(state *os.ProcessState) string {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal().String()
+	}
+	return ""
+}
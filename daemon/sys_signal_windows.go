@@ -0,0 +1,29 @@
+// +build windows
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// inheritFiles is false since ExtraFiles is not supported on windows
+const inheritFiles = false
+
+// masterSignals are handled by the master process
+var masterSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP}
+
+// isUpgrade is always false since SIGUSR2 is not supported on windows
+func isUpgrade(_ os.Signal) bool {
+	return false
+}
+
+func signalUpgrade(_ *os.Process) error {
+	return errors.New("upgrade is not supported on windows")
+}
+
+// exitSignal is always empty since signals are not supported on windows
+func exitSignal(_ *os.ProcessState) string {
+	return ""
+}
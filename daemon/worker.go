@@ -0,0 +1,145 @@
+package daemon
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const envWorkerID = "DAWN_DAEMON_WORKER_ID"
+
+// WorkerID returns the id of current worker process starting from
+// 0, or -1 if it's not a worker.
+func WorkerID() int {
+	if !isWorker() {
+		return -1
+	}
+
+	id, err := strconv.Atoi(os.Getenv(envWorkerID))
+	if err != nil {
+		return -1
+	}
+
+	return id
+}
+
+// worker is a worker process supervised by the master
+type worker struct {
+	id    int
+	cmd   *exec.Cmd
+	start time.Time
+	// ready is closed once the worker calls Ready
+	ready chan struct{}
+	// done is closed once the worker exits, with err set
+	done chan struct{}
+	err  error
+}
+
+// startWorker spawns a worker with the id and a pipe to report
+// readiness. Output of the worker is captured by logOutput if set.
+func startWorker(id int) (*worker, error) {
+	var (
+		r, w *os.File
+		c    *capture
+		err  error
+	)
+
+	if inheritFiles {
+		if r, w, err = os.Pipe(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := logOutput
+	if out != nil {
+		if c, err = newCapture(); err != nil {
+			if r != nil {
+				_ = r.Close()
+				_ = w.Close()
+			}
+			return nil, err
+		}
+	}
+
+	cmd, err := spawn(false, w, c, envWorkerID+"="+strconv.Itoa(id))
+	// the worker holds its own copies
+	if w != nil {
+		_ = w.Close()
+	}
+	if c != nil {
+		c.closeWriters()
+	}
+	if err != nil {
+		if r != nil {
+			_ = r.Close()
+		}
+		if c != nil {
+			c.closeReaders()
+		}
+		return nil, err
+	}
+
+	wk := &worker{
+		id:    id,
+		cmd:   cmd,
+		start: time.Now(),
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	if r != nil {
+		go wk.waitReady(r)
+	}
+
+	if c != nil {
+		out.spawned(wk)
+		c.copy(out, wk)
+	}
+
+	go func() {
+		wk.err = cmd.Wait()
+		if c != nil {
+			c.wait()
+			out.exited(wk)
+		}
+		close(wk.done)
+	}()
+
+	return wk, nil
+}
+
+func (w *worker) pid() int {
+	return w.cmd.Process.Pid
+}
+
+// waitReady closes ready once the worker writes to the pipe.
+// Reading ends with EOF if the worker exits without being ready.
+func (w *worker) waitReady(r *os.File) {
+	defer func() { _ = r.Close() }()
+
+	b := make([]byte, 1)
+	if n, _ := r.Read(b); n > 0 {
+		close(w.ready)
+	}
+}
+
+// stop asks the worker to stop with SIGTERM and kills it if it
+// doesn't exit within timeout, then waits for it to exit.
+func (w *worker) stop(logger *log.Logger, timeout time.Duration) {
+	_ = terminate(w.cmd.Process)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-w.done:
+	case <-timer.C:
+		logger.Printf("dawn: worker (pid:%d) didn't stop in %s, killing it", w.pid(), timeout)
+		_ = w.cmd.Process.Kill()
+		<-w.done
+	}
+
+	logger.Printf("dawn: (pid:%d)%v exist with err: %v", w.pid(), w.cmd.Args, w.err)
+}
@@ -0,0 +1,171 @@
+// +build !windows,!plan9
+
+package daemon
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerUpgrade(t *testing.T) {
+	at := assert.New(t)
+	logger := log.New(ioutil.Discard, "", 0)
+
+	config.Set("daemon.listen", "127.0.0.1:0")
+	defer config.Set("daemon.listen", nil)
+
+	require.Nil(t, setupListener())
+	defer teardownListener()
+
+	ln, err := net.FileListener(listenerFile)
+	require.Nil(t, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	t.Run("success", func(t *testing.T) {
+		old := startHelper(t, "serve")
+		at.Equal(old.pid(), dialPid(t, addr))
+
+		deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "serve"})
+		defer deck.TeardownEnvs()
+		deck.SetupCmd()
+		defer deck.TeardownCmd()
+
+		w, err := upgrade(old, logger, nil)
+		require.Nil(t, err)
+		defer w.stop(logger, time.Second)
+
+		// the old worker keeps running until it's stopped
+		select {
+		case <-old.done:
+			require.FailNow(t, "old worker exited")
+		case <-time.After(time.Millisecond * 100):
+		}
+
+		old.stop(logger, time.Second)
+		at.Equal(w.pid(), dialPid(t, addr))
+	})
+
+	t.Run("exit", func(t *testing.T) {
+		old := startHelper(t, "serve")
+		defer old.stop(logger, time.Second)
+
+		deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "exit"})
+		defer deck.TeardownEnvs()
+		deck.SetupCmd()
+		defer deck.TeardownCmd()
+
+		_, err := upgrade(old, logger, nil)
+		at.NotNil(err)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		config.Set("daemon.upgradeTimeout", "100ms")
+		defer config.Set("daemon.upgradeTimeout", nil)
+
+		old := startHelper(t, "serve")
+		defer old.stop(logger, time.Second)
+
+		deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "hang"})
+		defer deck.TeardownEnvs()
+		deck.SetupCmd()
+		defer deck.TeardownCmd()
+
+		_, err := upgrade(old, logger, nil)
+		at.NotNil(err)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		old := startHelper(t, "serve")
+		defer old.stop(logger, time.Second)
+
+		deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: "hang"})
+		defer deck.TeardownEnvs()
+		deck.SetupCmd()
+		defer deck.TeardownCmd()
+
+		cancel := make(chan struct{})
+		close(cancel)
+
+		_, err := upgrade(old, logger, cancel)
+		at.NotNil(err)
+	})
+
+	t.Run("spawn error", func(t *testing.T) {
+		deck.SetupCmdError()
+		defer deck.TeardownCmd()
+
+		_, err := upgrade(&worker{}, logger, nil)
+		at.NotNil(err)
+	})
+}
+
+// dialPid reads the pid written by a helper in serve mode
+func dialPid(t *testing.T, addr string) int {
+	conn, err := net.Dial("tcp", addr)
+	require.Nil(t, err)
+	defer func() { _ = conn.Close() }()
+
+	b, err := ioutil.ReadAll(conn)
+	require.Nil(t, err)
+
+	pid, err := strconv.Atoi(string(b))
+	require.Nil(t, err)
+
+	return pid
+}
+
+// startHelper starts a helper worker in the mode and
+// waits for it to be ready
+func startHelper(t *testing.T, mode string) *worker {
+	deck.SetupEnvs(deck.Envs{envDaemon: "", envTestWorker: mode, envTestPidFile: pidFilePath()})
+	defer deck.TeardownEnvs()
+
+	deck.SetupCmd()
+	defer deck.TeardownCmd()
+
+	w, err := startWorker(0)
+	require.Nil(t, err)
+
+	select {
+	case <-w.ready:
+	case <-w.done:
+		require.FailNow(t, "helper exited", "%v", w.err)
+	}
+
+	return w
+}
+
+func TestWorkerID(t *testing.T) {
+	at := assert.New(t)
+
+	at.Equal(-1, WorkerID())
+
+	deck.SetupEnvs(deck.Envs{envDaemonWorker: "", envWorkerID: "1"})
+	at.Equal(1, WorkerID())
+	deck.TeardownEnvs()
+
+	deck.SetupEnvs(deck.Envs{envDaemonWorker: "", envWorkerID: "a"})
+	at.Equal(-1, WorkerID())
+	deck.TeardownEnvs()
+}
+
+func TestExitSignal(t *testing.T) {
+	at := assert.New(t)
+
+	w := startHelper(t, "sleep")
+	require.Nil(t, w.cmd.Process.Kill())
+	<-w.done
+
+	at.Equal("killed", exitSignal(w.cmd.ProcessState))
+	at.Equal("", exitSignal(runHelper(t, "fail")))
+}
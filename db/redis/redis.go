@@ -3,21 +3,33 @@ package redis
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"sync"
+	"time"
 
 	"github.com/go-dawn/dawn"
 	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/health"
 	"github.com/go-redis/redis/v8"
 )
 
 var (
 	m        = &Module{}
 	fallback = "default"
+
+	hooksMut sync.Mutex
+	hooks    []func(name string, client *redis.Client)
 )
 
 type Module struct {
 	dawn.Module
-	conns    map[string]*redis.Client
+	mut   sync.RWMutex
+	conns map[string]*redis.Client
+	// opts are options in config which conns are created by
+	opts     map[string]*redis.Options
 	fallback string
+	// retired are clients replaced by Reload to be closed
+	retired map[*redis.Client]*time.Timer
 }
 
 // New gets the moduler
@@ -51,29 +63,136 @@ func (*Module) String() string {
 //  IdleTimeout = "1m"
 //  IdleCheckFrequency = "1m"
 func (m *Module) Init() dawn.Cleanup {
-	m.conns = make(map[string]*redis.Client)
+	m.conns, m.opts, m.fallback = connectAll(nil, nil)
+
+	return m.cleanup
+}
+
+// Reload rebuilds connections whose config changed. Previous
+// connections are kept if any of new connections fails to ping,
+// otherwise replaced ones are closed after a grace period, so that
+// clients got by Conn keep working meanwhile:
+//  [Redis]
+//  GracePeriod = "30s"
+func (m *Module) Reload() error {
+	m.mut.RLock()
+	old, oldOpts := m.conns, m.opts
+	m.mut.RUnlock()
+
+	conns, opts, fb := connectAll(old, oldOpts)
+
+	created := make(map[string]*redis.Client)
+	for name, client := range conns {
+		if old[name] != client {
+			created[name] = client
+		}
+	}
+
+	if err := ping(created); err != nil {
+		closeAll(created)
+		return err
+	}
+
+	grace := config.GetDuration("redis.gracePeriod", time.Second*30)
+
+	m.mut.Lock()
+	m.conns, m.opts, m.fallback = conns, opts, fb
+	for name, client := range old {
+		if conns[name] != client {
+			m.retire(client, grace)
+		}
+	}
+	m.mut.Unlock()
+
+	return nil
+}
+
+// retire closes the client after the grace period, or at
+// cleanup if it's earlier. m.mut must be held.
+func (m *Module) retire(client *redis.Client, grace time.Duration) {
+	if m.retired == nil {
+		m.retired = make(map[*redis.Client]*time.Timer)
+	}
+
+	m.retired[client] = time.AfterFunc(grace, func() {
+		m.mut.Lock()
+		delete(m.retired, client)
+		m.mut.Unlock()
+
+		_ = client.Close()
+	})
+}
+
+// connectAll connects each db in config, and clients in conns
+// are kept if their options in config don't change
+func connectAll(conns map[string]*redis.Client, opts map[string]*redis.Options) (
+	newConns map[string]*redis.Client, newOpts map[string]*redis.Options, fb string) {
+	newConns = make(map[string]*redis.Client)
+	newOpts = make(map[string]*redis.Options)
 
 	// extract redis config
 	c := config.Sub("redis")
 
-	m.fallback = c.GetString("default", fallback)
+	fb = c.GetString("default", fallback)
 
 	connsConfig := c.GetStringMap("connections")
 
-	// connect each db in config
+	hooksMut.Lock()
+	defer hooksMut.Unlock()
+
 	for name := range connsConfig {
-		cfg := c.Sub("connections." + name)
-		m.conns[name] = connect(name, cfg)
+		opt := options(c.Sub("connections." + name))
+		newOpts[name] = opt
+
+		if client, ok := conns[name]; ok && reflect.DeepEqual(opts[name], opt) {
+			newConns[name] = client
+			continue
+		}
+
+		// options are changed by redis with defaults
+		o := *opt
+		newConns[name] = redis.NewClient(&o)
+
+		for _, fn := range hooks {
+			fn(name, newConns[name])
+		}
 	}
 
-	return m.cleanup
+	return
 }
 
-func connect(name string, c *config.Config) (client *redis.Client) {
-	addr := c.GetString("Addr", "127.0.0.1:6379")
-	client = redis.NewClient(&redis.Options{
+// OnConnect registers a hook which is called with each client
+// created later and also the existing ones. It's useful to
+// instrument clients with redis.Hook.
+func OnConnect(fn func(name string, client *redis.Client)) {
+	hooksMut.Lock()
+	defer hooksMut.Unlock()
+
+	hooks = append(hooks, fn)
+
+	for name, client := range Conns() {
+		fn(name, client)
+	}
+}
+
+// Conns returns all clients by their names
+func Conns() map[string]*redis.Client {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	conns := make(map[string]*redis.Client, len(m.conns))
+	for name, client := range m.conns {
+		conns[name] = client
+	}
+
+	return conns
+}
+
+// options returns options of a connection in config
+func options(c *config.Config) *redis.Options {
+	return &redis.Options{
 		Network:            c.GetString("Network"),
-		Addr:               addr,
+		Addr:               c.GetString("Addr", "127.0.0.1:6379"),
 		Username:           c.GetString("Username"),
 		Password:           c.GetString("Password"),
 		DB:                 c.GetInt("DB"),
@@ -87,28 +206,80 @@ func connect(name string, c *config.Config) (client *redis.Client) {
 		PoolTimeout:        c.GetDuration("PoolTimeout"),
 		IdleTimeout:        c.GetDuration("IdleTimeout"),
 		IdleCheckFrequency: c.GetDuration("IdleCheckFrequency"),
-	})
-
-	return
+	}
 }
 
+// Boot pings every connection and panics if any of them fails
 func (m *Module) Boot() {
-	for name, client := range m.conns {
+	if err := m.BootE(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// BootE pings every connection and returns error if any of them fails
+func (m *Module) BootE() error {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	return ping(m.conns)
+}
+
+func ping(conns map[string]*redis.Client) error {
+	for name, client := range conns {
 		if _, err := client.Ping(context.Background()).Result(); err != nil {
-			panic(fmt.Sprintf("dawn:redis failed to ping %s(%s): %v", name, client.Options().Addr, err))
+			return fmt.Errorf("dawn:redis failed to ping %s(%s): %v", name, client.Options().Addr, err)
 		}
 	}
+
+	return nil
+}
+
+// Check pings every connection and reports
+// failed ones in health.Errors
+func (m *Module) Check(ctx context.Context) error {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	errs := health.Errors{}
+	for name, client := range m.conns {
+		if err := client.Ping(ctx).Err(); err != nil {
+			errs[name] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
 }
 
 func (m *Module) cleanup() {
-	// close every connections
-	for _, client := range m.conns {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	closeAll(m.conns)
+
+	for client, timer := range m.retired {
+		if timer.Stop() {
+			_ = client.Close()
+		}
+		delete(m.retired, client)
+	}
+}
+
+// closeAll closes every connections
+func closeAll(conns map[string]*redis.Client) {
+	for _, client := range conns {
 		_ = client.Close()
 	}
 }
 
 // Conn gets redis connection by specific name or fallback
 func Conn(name ...string) redis.Cmdable {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
 	n := m.fallback
 
 	if len(name) > 0 && name[0] != "" {
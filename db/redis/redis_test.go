@@ -1,13 +1,21 @@
 package redis
 
 import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/health"
 
 	"github.com/go-redis/redis/v8"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_Redis_Module_Name(t *testing.T) {
@@ -36,6 +44,8 @@ func Test_Redis_Module_Boot(t *testing.T) {
 	}
 
 	assert.Panics(t, m.Boot)
+
+	assert.Contains(t, m.BootE().Error(), "dawn:redis failed to ping default(127.0.0.1:99999)")
 }
 
 func Test_Redis_Cleanup(t *testing.T) {
@@ -51,3 +61,137 @@ func Test_Redis_Cleanup(t *testing.T) {
 func Test_Redis_Conn(t *testing.T) {
 	assert.Nil(t, Conn("non"))
 }
+
+func Test_Redis_Module_Reload(t *testing.T) {
+	client := redis.NewClient(&redis.Options{})
+	m := &Module{
+		conns: map[string]*redis.Client{
+			fallback: client,
+		},
+	}
+
+	config.Set("Redis.Connections.Default.Addr", "127.0.0.1:99999")
+	assert.Contains(t, m.Reload().Error(), "dawn:redis failed to ping default(127.0.0.1:99999)")
+	assert.Equal(t, client, m.conns[fallback])
+
+	config.Set("Redis", nil)
+	config.Set("Redis.Default", "new")
+	assert.NoError(t, m.Reload())
+	assert.Len(t, m.conns, 0)
+	assert.Equal(t, "new", m.fallback)
+}
+
+func Test_Redis_Module_Reload_Changed(t *testing.T) {
+	addr := fakeRedis(t)
+
+	config.Set("Redis.GracePeriod", "50ms")
+	config.Set("Redis.Connections.a.Addr", addr)
+	config.Set("Redis.Connections.b.Addr", addr)
+	defer config.Set("Redis", nil)
+
+	m := &Module{}
+	defer m.Init()()
+	a, b := m.conns["a"], m.conns["b"]
+
+	config.Set("Redis.Connections.b.PoolSize", 2)
+	require.NoError(t, m.Reload())
+
+	// only the changed one is replaced
+	assert.Same(t, a, m.conns["a"])
+	assert.NotSame(t, b, m.conns["b"])
+	assert.Equal(t, 2, m.conns["b"].Options().PoolSize)
+
+	// and closed after the grace period
+	assert.NoError(t, b.Ping(context.Background()).Err())
+	assert.Eventually(t, func() bool {
+		return b.Ping(context.Background()).Err() == redis.ErrClosed
+	}, time.Second, time.Millisecond*10)
+	assert.NoError(t, a.Ping(context.Background()).Err())
+
+	t.Run("cleanup", func(t *testing.T) {
+		config.Set("Redis.GracePeriod", "1h")
+		config.Set("Redis.Connections.a.PoolSize", 2)
+		require.NoError(t, m.Reload())
+
+		m.cleanup()
+		assert.Equal(t, redis.ErrClosed, a.Ping(context.Background()).Err())
+		assert.Len(t, m.retired, 0)
+	})
+}
+
+// fakeRedis serves a redis server replying PONG to every command
+func fakeRedis(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+
+				r := bufio.NewReader(conn)
+				for {
+					// a command is an array of bulk strings
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					for i := 0; i < n*2; i++ {
+						if _, err = r.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					if _, err = conn.Write([]byte("+PONG\r\n")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func Test_Redis_Module_Check(t *testing.T) {
+	m := &Module{
+		conns: map[string]*redis.Client{
+			fallback: redis.NewClient(&redis.Options{
+				Addr: "127.0.0.1:99999",
+			}),
+		},
+	}
+
+	err := m.Check(context.Background())
+	require.IsType(t, health.Errors{}, err)
+	assert.Contains(t, err.(health.Errors), fallback)
+
+	assert.NoError(t, (&Module{}).Check(context.Background()))
+}
+
+func Test_Redis_OnConnect(t *testing.T) {
+	defer func() { hooks = nil }()
+
+	client := redis.NewClient(&redis.Options{})
+	m.conns = map[string]*redis.Client{"existing": client}
+	defer func() { m.conns = nil }()
+
+	var names []string
+	OnConnect(func(name string, client *redis.Client) {
+		names = append(names, name)
+	})
+	assert.Equal(t, []string{"existing"}, names)
+	assert.Equal(t, map[string]*redis.Client{"existing": client}, Conns())
+
+	config.Set("Redis.Connections.new.Addr", "127.0.0.1:99999")
+	defer config.Set("Redis", nil)
+
+	conns, _, _ := connectAll(nil, nil)
+	assert.Contains(t, conns, "new")
+	assert.Contains(t, names, "new")
+}
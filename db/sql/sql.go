@@ -1,12 +1,15 @@
 package sql
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/go-dawn/dawn"
 	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/health"
 	"github.com/go-dawn/pkg/deck"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -18,6 +21,9 @@ import (
 var (
 	m        = &Module{}
 	fallback = "testing"
+
+	hooksMut sync.Mutex
+	hooks    []func(name string, db *gorm.DB) error
 )
 
 type Module struct {
@@ -45,6 +51,17 @@ func (*Module) String() string {
 //  [Sql.Connections.mysql]
 //  Driver = "mysql"
 func (m *Module) Init() dawn.Cleanup {
+	cleanup, err := m.InitE()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return cleanup
+}
+
+// InitE is the same as Init but returns error instead of panicking.
+// Connections already established are closed by the returned cleanup.
+func (m *Module) InitE() (dawn.Cleanup, error) {
 	m.conns = make(map[string]*gorm.DB)
 
 	// extract sql config
@@ -55,17 +72,87 @@ func (m *Module) Init() dawn.Cleanup {
 	connsConfig := c.GetStringMap("connections")
 
 	if len(connsConfig) == 0 {
-		m.conns[m.fallback] = connect(m.fallback, config.New())
-		return m.cleanup
+		return m.cleanup, m.add(m.fallback, config.New())
 	}
 
 	// connect each db in config
 	for name := range connsConfig {
-		cfg := c.Sub("connections." + name)
-		m.conns[name] = connect(name, cfg)
+		if err := m.add(name, c.Sub("connections."+name)); err != nil {
+			return m.cleanup, err
+		}
+	}
+
+	return m.cleanup, nil
+}
+
+// add connects the db and calls hooks registered by OnConnect
+func (m *Module) add(name string, c *config.Config) error {
+	db, err := connect(name, c)
+	if err != nil {
+		return err
+	}
+
+	m.conns[name] = db
+
+	hooksMut.Lock()
+	defer hooksMut.Unlock()
+
+	for _, fn := range hooks {
+		if err := fn(name, db); err != nil {
+			return fmt.Errorf("dawn:sql failed to setup %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// OnConnect registers a hook which is called with each connection
+// established later and also the existing ones. It's useful to
+// instrument connections with gorm callbacks or plugins.
+func OnConnect(fn func(name string, db *gorm.DB) error) error {
+	hooksMut.Lock()
+	defer hooksMut.Unlock()
+
+	hooks = append(hooks, fn)
+
+	for name, db := range m.conns {
+		if err := fn(name, db); err != nil {
+			return fmt.Errorf("dawn:sql failed to setup %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Conns returns all connections by their names
+func Conns() map[string]*gorm.DB {
+	conns := make(map[string]*gorm.DB, len(m.conns))
+	for name, db := range m.conns {
+		conns[name] = db
+	}
+
+	return conns
+}
+
+// Check pings every connection and reports
+// failed ones in health.Errors
+func (m *Module) Check(ctx context.Context) error {
+	errs := health.Errors{}
+	for name, gdb := range m.conns {
+		db, err := gdb.DB()
+		if err == nil {
+			err = db.PingContext(ctx)
+		}
+		if err != nil {
+			errs[name] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
 	}
 
-	return m.cleanup
+	return nil
 }
 
 // cleanup 	close every connections
@@ -77,10 +164,9 @@ func (m *Module) cleanup() {
 	}
 }
 
-func connect(name string, c *config.Config) (db *gorm.DB) {
+func connect(name string, c *config.Config) (db *gorm.DB, err error) {
 	driver := c.GetString("driver", "sqlite")
 
-	var err error
 	switch strings.ToLower(driver) {
 	case "sqlite":
 		db, err = resolveSqlite(c)
@@ -89,11 +175,11 @@ func connect(name string, c *config.Config) (db *gorm.DB) {
 	case "postgres":
 		db, err = resolvePostgres(c)
 	default:
-		panic(fmt.Sprintf("dawn:sql unknown driver %s of %s", driver, name))
+		return nil, fmt.Errorf("dawn:sql unknown driver %s of %s", driver, name)
 	}
 
 	if err != nil || db == nil {
-		panic(fmt.Sprintf("dawn:sql failed to connect %s(%s): %v", name, driver, err))
+		return nil, fmt.Errorf("dawn:sql failed to connect %s(%s): %v", name, driver, err)
 	}
 
 	return
@@ -2,11 +2,14 @@ package sql
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/health"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -46,6 +49,20 @@ func Test_Sql_Module_Init(t *testing.T) {
 	})
 }
 
+func Test_Sql_Module_InitE(t *testing.T) {
+	config.Set("sql.connections.unknown", map[string]interface{}{"driver": "unknown"})
+	defer config.Set("sql", nil)
+
+	m := &Module{}
+
+	cleanup, err := m.InitE()
+	assert.NotNil(t, cleanup)
+	assert.EqualError(t, err, "dawn:sql unknown driver unknown of unknown")
+	cleanup()
+
+	assert.Panics(t, func() { m.Init() })
+}
+
 func Test_Sql_Conn(t *testing.T) {
 	assert.Nil(t, Conn("non"))
 }
@@ -54,17 +71,16 @@ func Test_Sql_connect(t *testing.T) {
 	t.Parallel()
 
 	t.Run("unknown driver", func(t *testing.T) {
-		defer func() {
-			assert.Equal(t, "dawn:sql unknown driver test of name", recover())
-		}()
 		c := config.New()
 		c.Set("driver", "test")
-		connect("name", c)
+		_, err := connect("name", c)
+		assert.EqualError(t, err, "dawn:sql unknown driver test of name")
 	})
 
 	t.Run("sqlite", func(t *testing.T) {
 		c := config.New()
-		gdb := connect("name", c)
+		gdb, err := connect("name", c)
+		assert.NoError(t, err)
 
 		gdb.Logger.LogMode(logger.Info)
 		ctx := context.Background()
@@ -74,27 +90,59 @@ func Test_Sql_connect(t *testing.T) {
 	})
 
 	t.Run("mysql", func(t *testing.T) {
-		defer func() {
-			assert.Contains(t, recover(),
-				"dawn:sql failed to connect name(mysql):")
-		}()
-
 		c := config.New()
 		c.Set("Driver", "mysql")
 		c.Set("ParseTime", false)
 		c.Set("Testing", true)
-		connect("name", c)
+		_, err := connect("name", c)
+		assert.Contains(t, err.Error(), "dawn:sql failed to connect name(mysql):")
 	})
 
 	t.Run("postgres", func(t *testing.T) {
-		defer func() {
-			assert.Contains(t, recover(),
-				"dawn:sql failed to connect name(postgres):")
-		}()
-
 		c := config.New()
 		c.Set("Driver", "postgres")
 		c.Set("Testing", true)
-		connect("name", c)
+		_, err := connect("name", c)
+		assert.Contains(t, err.Error(), "dawn:sql failed to connect name(postgres):")
 	})
 }
+
+func Test_Sql_Module_Check(t *testing.T) {
+	gdb, err := connect("sqlite", config.New())
+	require.NoError(t, err)
+
+	m := &Module{conns: map[string]*gorm.DB{"sqlite": gdb}}
+	assert.NoError(t, m.Check(context.Background()))
+
+	m.cleanup()
+	err = m.Check(context.Background())
+	require.IsType(t, health.Errors{}, err)
+	assert.Contains(t, err.Error(), "sqlite: sql: database is closed")
+}
+
+func Test_Sql_OnConnect(t *testing.T) {
+	defer func() { hooks = nil }()
+
+	var names []string
+	require.NoError(t, OnConnect(func(name string, db *gorm.DB) error {
+		names = append(names, name)
+		return nil
+	}))
+
+	m := New()
+	cleanup, err := m.InitE()
+	require.NoError(t, err)
+	defer cleanup()
+
+	assert.Equal(t, []string{fallback}, names)
+	assert.Len(t, Conns(), 1)
+
+	// apply to existing connections
+	assert.Error(t, OnConnect(func(string, *gorm.DB) error {
+		return errors.New("hook error")
+	}))
+
+	// failed to setup new connections
+	_, err = m.InitE()
+	assert.EqualError(t, err, "dawn:sql failed to setup testing: hook error")
+}
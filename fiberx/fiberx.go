@@ -1,16 +1,13 @@
 package fiberx
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"strings"
-	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
-	"github.com/valyala/bytebufferpool"
-	"github.com/valyala/fasthttp"
 )
 
 // ErrHandler is Dawn's error handler
@@ -219,74 +216,21 @@ func RespAlreadyReported(c *fiber.Ctx, msg ...string) error {
 	return respCommon(c, fiber.StatusAlreadyReported, msg...)
 }
 
-var pid = os.Getpid()
+// userContextKey is the locals key of the user context
+const userContextKey = "dawn:user_context"
 
-// Logger logs request and response info to os.Stdout
-// or os.Stderr. The format is:
-// time #pid[ request-id]: latency status clientIP method protocol://host_path[ error]
-func Logger() fiber.Handler {
-	return func(ctx *fiber.Ctx) (err error) {
-		start := time.Now()
-
-		err = ctx.Next()
-
-		end := time.Now()
-		latency := end.Sub(start).Truncate(time.Microsecond)
-
-		bb := bytebufferpool.Get()
-		defer bytebufferpool.Put(bb)
-
-		// append time
-		bb.B = end.AppendFormat(bb.B, "2006/01/02 15:04:05.000")
-
-		// append pid
-		_, _ = bb.WriteString(" #")
-		bb.B = fasthttp.AppendUint(bb.B, pid)
-
-		// append request id
-		if requestId := ctx.Response().Header.Peek(fiber.HeaderXRequestID); len(requestId) > 0 {
-			_ = bb.WriteByte(' ')
-			_, _ = bb.Write(requestId)
-		}
-		_, _ = bb.WriteString(": ")
-
-		// append latency
-		_, _ = bb.WriteString(latency.String())
-		_ = bb.WriteByte(' ')
-
-		// append status code
-		statusCode := ctx.Response().StatusCode()
-		bb.B = fasthttp.AppendUint(bb.B, statusCode)
-		_ = bb.WriteByte(' ')
-
-		// append client ip
-		_, _ = bb.WriteString(ctx.IP())
-		_ = bb.WriteByte(' ')
-
-		// append http method
-		_, _ = bb.WriteString(ctx.Method())
-		_ = bb.WriteByte(' ')
-
-		// append http protocol://host/uri
-		_, _ = bb.WriteString(ctx.Protocol())
-		_, _ = bb.WriteString("://")
-		_, _ = bb.Write(ctx.Request().URI().Host())
-		_, _ = bb.Write(ctx.Request().RequestURI())
-
-		w := os.Stdout
-		// append error
-		if err != nil {
-			_ = bb.WriteByte(' ')
-			_, _ = bb.WriteString(err.Error())
-			w = os.Stderr
-		}
-
-		// append newline
-		_ = bb.WriteByte('\n')
-
-		// ignore error on purpose
-		_, _ = bb.WriteTo(w)
-
-		return
+// UserContext returns the context set by SetUserContext,
+// or context.Background() if none. It stands in for c.UserContext()
+// of later fiber versions, and the two don't share the context.
+func UserContext(c *fiber.Ctx) context.Context {
+	if ctx, ok := c.Locals(userContextKey).(context.Context); ok {
+		return ctx
 	}
+	return context.Background()
+}
+
+// SetUserContext sets a context carrying request scoped values,
+// such as a trace span, for the rest of handlers
+func SetUserContext(c *fiber.Ctx, ctx context.Context) {
+	c.Locals(userContextKey, ctx)
 }
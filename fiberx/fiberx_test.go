@@ -2,6 +2,7 @@ package fiberx
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -258,14 +259,18 @@ func Test_Fiberx_RequestID(t *testing.T) {
 	})
 }
 
-func Test_Fiberx_Logger(t *testing.T) {
+func Test_Fiberx_UserContext(t *testing.T) {
 	t.Parallel()
 
-	app := fiber.New(fiber.Config{ErrorHandler: ErrHandler})
-	app.Use(Logger())
+	type key struct{}
 
+	app := fiber.New()
 	app.Get("/", func(c *fiber.Ctx) error {
-		c.Set(fiber.HeaderXRequestID, "id")
+		assert.Equal(t, context.Background(), UserContext(c))
+
+		SetUserContext(c, context.WithValue(context.Background(), key{}, "value"))
+		assert.Equal(t, "value", UserContext(c).Value(key{}))
+
 		return RespOK(c)
 	})
 
@@ -274,19 +279,7 @@ func Test_Fiberx_Logger(t *testing.T) {
 		method:     fiber.MethodGet,
 		target:     "/",
 		statusCode: fiber.StatusOK,
-		respBody:   `{"code":200,"message":"OK","request_id":"id"}`,
-	})
-
-	app.Get("/error", func(c *fiber.Ctx) error {
-		return fiber.ErrForbidden
-	})
-
-	assertRespCase(t, respCase{
-		app:        app,
-		method:     fiber.MethodGet,
-		target:     "/error",
-		statusCode: fiber.StatusForbidden,
-		respBody:   `{"code":403,"message":"Forbidden"}`,
+		respBody:   `{"code":200,"message":"OK"}`,
 	})
 }
 
@@ -0,0 +1,373 @@
+package fiberx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-dawn/dawn/internal/jsonenc"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/bytebufferpool"
+	"github.com/valyala/fasthttp"
+)
+
+// Optional fields of access logs
+const (
+	// FieldRoute is the matched route pattern
+	FieldRoute = "route"
+	// FieldUserAgent is the User-Agent request header
+	FieldUserAgent = "user_agent"
+	// FieldBytesIn is the size of request body
+	FieldBytesIn = "bytes_in"
+	// FieldBytesOut is the size of response body
+	FieldBytesOut = "bytes_out"
+	// FieldReferer is the Referer request header
+	FieldReferer = "referer"
+)
+
+// LoggerConfig defines the config for Logger middleware.
+type LoggerConfig struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c *fiber.Ctx) bool
+
+	// JSON outputs a json object per line instead of the text layout.
+	//
+	// Optional. Default: false
+	JSON bool
+
+	// Fields are optional fields to be logged, can be FieldRoute,
+	// FieldUserAgent, FieldBytesIn, FieldBytesOut and FieldReferer.
+	//
+	// Optional. Default: nil
+	Fields []string
+
+	// Headers are names of request headers to be logged.
+	//
+	// Optional. Default: nil
+	Headers []string
+
+	// SkipPaths are paths not to be logged, such as health checks.
+	//
+	// Optional. Default: nil
+	SkipPaths []string
+
+	// SampleRate is the ratio of successful requests to be logged,
+	// in the range of (0, 1]. Requests with an error or a status code
+	// greater than or equal to 400 are always logged.
+	//
+	// Optional. Default: 1
+	SampleRate float64
+
+	// Output is the writer of successful requests.
+	//
+	// Optional. Default: os.Stdout
+	Output io.Writer
+
+	// ErrOutput is the writer of requests with an error. It falls
+	// back to Output if only Output is set.
+	//
+	// Optional. Default: os.Stderr
+	ErrOutput io.Writer
+}
+
+// LoggerConfigDefault is the default config
+var LoggerConfigDefault = LoggerConfig{
+	SampleRate: 1,
+	Output:     os.Stdout,
+	ErrOutput:  os.Stderr,
+}
+
+func loggerConfigDefault(config ...LoggerConfig) LoggerConfig {
+	if len(config) < 1 {
+		return LoggerConfigDefault
+	}
+
+	cfg := config[0]
+
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = LoggerConfigDefault.SampleRate
+	}
+	if cfg.ErrOutput == nil {
+		cfg.ErrOutput = cfg.Output
+	}
+	if cfg.Output == nil {
+		cfg.Output = LoggerConfigDefault.Output
+	}
+	if cfg.ErrOutput == nil {
+		cfg.ErrOutput = LoggerConfigDefault.ErrOutput
+	}
+
+	for _, f := range cfg.Fields {
+		switch f {
+		case FieldRoute, FieldUserAgent, FieldBytesIn, FieldBytesOut, FieldReferer:
+		default:
+			panic(fmt.Sprintf("fiberx: unknown logger field %s", f))
+		}
+	}
+
+	return cfg
+}
+
+var pid = os.Getpid()
+
+// Logger logs request and response info to os.Stdout
+// or os.Stderr by default. The text format is:
+// time #pid[ request-id]: latency status clientIP method protocol://host_path[ fields][ error]
+// where fields are key=value pairs of optional fields and headers.
+// The json format has keys time, pid, request_id, latency, status,
+// ip, method, url, optional fields, headers and error.
+func Logger(config ...LoggerConfig) fiber.Handler {
+	cfg := loggerConfigDefault(config...)
+
+	skips := make(map[string]bool, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skips[p] = true
+	}
+
+	var count uint64
+
+	return func(ctx *fiber.Ctx) (err error) {
+		if cfg.Next != nil && cfg.Next(ctx) {
+			return ctx.Next()
+		}
+
+		if skips[ctx.Path()] {
+			return ctx.Next()
+		}
+
+		start := time.Now()
+		self := ctx.Route()
+
+		err = ctx.Next()
+
+		statusCode := ctx.Response().StatusCode()
+		if err != nil {
+			// the error handler is not called yet
+			statusCode = errStatus(err)
+		}
+
+		if err == nil && statusCode < fiber.StatusBadRequest && cfg.SampleRate < 1 {
+			// log evenly spaced requests
+			n := atomic.AddUint64(&count, 1)
+			if uint64(float64(n)*cfg.SampleRate) == uint64(float64(n-1)*cfg.SampleRate) {
+				return
+			}
+		}
+
+		end := time.Now()
+		e := &accessEntry{
+			ctx:     ctx,
+			cfg:     &cfg,
+			end:     end,
+			latency: end.Sub(start).Truncate(time.Microsecond),
+			status:  statusCode,
+			err:     err,
+		}
+
+		if ctx.Route() != self || statusCode != fiber.StatusNotFound {
+			e.route = ctx.Route().Path
+		}
+
+		bb := bytebufferpool.Get()
+		defer bytebufferpool.Put(bb)
+
+		if cfg.JSON {
+			e.appendJSON(bb)
+		} else {
+			e.appendText(bb)
+		}
+
+		w := cfg.Output
+		if err != nil {
+			w = cfg.ErrOutput
+		}
+
+		// ignore error on purpose
+		_, _ = bb.WriteTo(w)
+
+		return
+	}
+}
+
+// errStatus returns the status code the error handler responds with
+func errStatus(err error) int {
+	switch e := err.(type) {
+	case *fiber.Error:
+		return e.Code
+	case *Error:
+		return e.code
+	case validator.ValidationErrors:
+		return fiber.StatusUnprocessableEntity
+	}
+	return fiber.StatusInternalServerError
+}
+
+// accessEntry holds info of a request to be logged
+type accessEntry struct {
+	ctx     *fiber.Ctx
+	cfg     *LoggerConfig
+	end     time.Time
+	latency time.Duration
+	status  int
+	route   string
+	err     error
+}
+
+func (e *accessEntry) field(name string) (value string, isNumber bool) {
+	switch name {
+	case FieldRoute:
+		return e.route, false
+	case FieldUserAgent:
+		return string(e.ctx.Request().Header.UserAgent()), false
+	case FieldBytesIn:
+		return strconv.Itoa(len(e.ctx.Request().Body())), true
+	case FieldBytesOut:
+		return strconv.Itoa(len(e.ctx.Response().Body())), true
+	case FieldReferer:
+		return string(e.ctx.Request().Header.Referer()), false
+	}
+	return "", false
+}
+
+func (e *accessEntry) appendText(bb *bytebufferpool.ByteBuffer) {
+	ctx := e.ctx
+
+	// append time
+	bb.B = e.end.AppendFormat(bb.B, "2006/01/02 15:04:05.000")
+
+	// append pid
+	_, _ = bb.WriteString(" #")
+	bb.B = fasthttp.AppendUint(bb.B, pid)
+
+	// append request id
+	if requestId := ctx.Response().Header.Peek(fiber.HeaderXRequestID); len(requestId) > 0 {
+		_ = bb.WriteByte(' ')
+		_, _ = bb.Write(requestId)
+	}
+	_, _ = bb.WriteString(": ")
+
+	// append latency
+	_, _ = bb.WriteString(e.latency.String())
+	_ = bb.WriteByte(' ')
+
+	// append status code
+	bb.B = fasthttp.AppendUint(bb.B, e.status)
+	_ = bb.WriteByte(' ')
+
+	// append client ip
+	_, _ = bb.WriteString(ctx.IP())
+	_ = bb.WriteByte(' ')
+
+	// append http method
+	_, _ = bb.WriteString(ctx.Method())
+	_ = bb.WriteByte(' ')
+
+	// append http protocol://host/uri
+	_, _ = bb.WriteString(ctx.Protocol())
+	_, _ = bb.WriteString("://")
+	_, _ = bb.Write(ctx.Request().URI().Host())
+	_, _ = bb.Write(ctx.Request().RequestURI())
+
+	// append optional fields
+	for _, name := range e.cfg.Fields {
+		value, isNumber := e.field(name)
+		_ = bb.WriteByte(' ')
+		_, _ = bb.WriteString(name)
+		_ = bb.WriteByte('=')
+		if isNumber {
+			_, _ = bb.WriteString(value)
+		} else {
+			bb.B = strconv.AppendQuote(bb.B, value)
+		}
+	}
+
+	// append headers
+	for _, name := range e.cfg.Headers {
+		_ = bb.WriteByte(' ')
+		_, _ = bb.WriteString(strings.ToLower(name))
+		_ = bb.WriteByte('=')
+		bb.B = strconv.AppendQuote(bb.B, ctx.Get(name))
+	}
+
+	// append error
+	if e.err != nil {
+		_ = bb.WriteByte(' ')
+		_, _ = bb.WriteString(e.err.Error())
+	}
+
+	// append newline
+	_ = bb.WriteByte('\n')
+}
+
+func (e *accessEntry) appendJSON(bb *bytebufferpool.ByteBuffer) {
+	ctx := e.ctx
+
+	_, _ = bb.WriteString(`{"time":`)
+	_ = bb.WriteByte('"')
+	bb.B = e.end.AppendFormat(bb.B, "2006-01-02T15:04:05.000Z07:00")
+	_ = bb.WriteByte('"')
+
+	_, _ = bb.WriteString(`,"pid":`)
+	bb.B = fasthttp.AppendUint(bb.B, pid)
+
+	if requestId := ctx.Response().Header.Peek(fiber.HeaderXRequestID); len(requestId) > 0 {
+		_, _ = bb.WriteString(`,"request_id":`)
+		bb.B = jsonenc.AppendString(bb.B, string(requestId))
+	}
+
+	_, _ = bb.WriteString(`,"latency":`)
+	bb.B = jsonenc.AppendString(bb.B, e.latency.String())
+
+	_, _ = bb.WriteString(`,"status":`)
+	bb.B = fasthttp.AppendUint(bb.B, e.status)
+
+	_, _ = bb.WriteString(`,"ip":`)
+	bb.B = jsonenc.AppendString(bb.B, ctx.IP())
+
+	_, _ = bb.WriteString(`,"method":`)
+	bb.B = jsonenc.AppendString(bb.B, ctx.Method())
+
+	_, _ = bb.WriteString(`,"url":`)
+	bb.B = jsonenc.AppendString(bb.B, ctx.Protocol()+"://"+
+		string(ctx.Request().URI().Host())+string(ctx.Request().RequestURI()))
+
+	for _, name := range e.cfg.Fields {
+		value, isNumber := e.field(name)
+		_ = bb.WriteByte(',')
+		bb.B = jsonenc.AppendString(bb.B, name)
+		_ = bb.WriteByte(':')
+		if isNumber {
+			_, _ = bb.WriteString(value)
+		} else {
+			bb.B = jsonenc.AppendString(bb.B, value)
+		}
+	}
+
+	if len(e.cfg.Headers) > 0 {
+		_, _ = bb.WriteString(`,"headers":{`)
+		for i, name := range e.cfg.Headers {
+			if i > 0 {
+				_ = bb.WriteByte(',')
+			}
+			bb.B = jsonenc.AppendString(bb.B, strings.ToLower(name))
+			_ = bb.WriteByte(':')
+			bb.B = jsonenc.AppendString(bb.B, ctx.Get(name))
+		}
+		_ = bb.WriteByte('}')
+	}
+
+	if e.err != nil {
+		_, _ = bb.WriteString(`,"error":`)
+		bb.B = jsonenc.AppendString(bb.B, e.err.Error())
+	}
+
+	_, _ = bb.WriteString("}\n")
+}
+
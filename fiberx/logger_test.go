@@ -0,0 +1,212 @@
+package fiberx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Fiberx_Logger(t *testing.T) {
+	t.Parallel()
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrHandler})
+	app.Use(Logger())
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderXRequestID, "id")
+		return RespOK(c)
+	})
+
+	assertRespCase(t, respCase{
+		app:        app,
+		method:     fiber.MethodGet,
+		target:     "/",
+		statusCode: fiber.StatusOK,
+		respBody:   `{"code":200,"message":"OK","request_id":"id"}`,
+	})
+
+	app.Get("/error", func(c *fiber.Ctx) error {
+		return fiber.ErrForbidden
+	})
+
+	assertRespCase(t, respCase{
+		app:        app,
+		method:     fiber.MethodGet,
+		target:     "/error",
+		statusCode: fiber.StatusForbidden,
+		respBody:   `{"code":403,"message":"Forbidden"}`,
+	})
+}
+
+func Test_Fiberx_Logger_Text(t *testing.T) {
+	t.Parallel()
+
+	var out, errOut bytes.Buffer
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrHandler})
+	app.Use(Logger(LoggerConfig{
+		Fields:    []string{FieldRoute, FieldUserAgent, FieldBytesIn, FieldBytesOut, FieldReferer},
+		Headers:   []string{"X-Custom"},
+		Output:    &out,
+		ErrOutput: &errOut,
+	}))
+	app.Post("/users/:id", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderXRequestID, "id")
+		return c.SendString("user")
+	})
+	app.Get("/error", func(c *fiber.Ctx) error {
+		return errors.New("error")
+	})
+
+	req := httptest.NewRequest(fiber.MethodPost, "/users/1", strings.NewReader("body"))
+	req.Header.Set(fiber.HeaderUserAgent, "agent")
+	req.Header.Set(fiber.HeaderReferer, "http://referer")
+	req.Header.Set("X-Custom", `"custom"`)
+	_, err := app.Test(req)
+	require.NoError(t, err)
+
+	assert.Contains(t, out.String(), " #")
+	assert.Contains(t, out.String(), " id: ")
+	assert.Contains(t, out.String(), ` 200 0.0.0.0 POST http://example.com/users/1 route="/users/:id" `+
+		`user_agent="agent" bytes_in=4 bytes_out=4 referer="http://referer" x-custom="\"custom\""`+"\n")
+
+	_, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/error", nil))
+	require.NoError(t, err)
+	assert.Contains(t, errOut.String(), ` 500 0.0.0.0 GET http://example.com/error route="/error" `)
+	assert.True(t, strings.HasSuffix(errOut.String(), " error\n"))
+}
+
+func Test_Fiberx_Logger_JSON(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrHandler})
+	app.Use(Logger(LoggerConfig{
+		JSON:    true,
+		Fields:  []string{FieldRoute, FieldBytesOut},
+		Headers: []string{"X-Custom"},
+		Output:  &out,
+	}))
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderXRequestID, "id")
+		return c.SendString("user")
+	})
+	app.Get("/error", func(c *fiber.Ctx) error {
+		return errors.New("error\n\"quoted\"\x01")
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/users/1", nil)
+	req.Header.Set("X-Custom", "值")
+	_, err := app.Test(req)
+	require.NoError(t, err)
+
+	_, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/non", nil))
+	require.NoError(t, err)
+
+	_, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/error", nil))
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "id", entry["request_id"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "http://example.com/users/1", entry["url"])
+	assert.Equal(t, "/users/:id", entry["route"])
+	assert.Equal(t, float64(4), entry["bytes_out"])
+	assert.Equal(t, map[string]interface{}{"x-custom": "值"}, entry["headers"])
+	assert.NotEmpty(t, entry["time"])
+	assert.NotEmpty(t, entry["pid"])
+	assert.NotEmpty(t, entry["latency"])
+
+	entry = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, float64(404), entry["status"])
+	assert.Equal(t, "", entry["route"])
+
+	entry = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, "error\n\"quoted\"\x01", entry["error"])
+}
+
+func Test_Fiberx_Logger_Skip(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	app := fiber.New()
+	app.Use(Logger(LoggerConfig{
+		Next: func(c *fiber.Ctx) bool {
+			return c.Method() == fiber.MethodHead
+		},
+		SkipPaths: []string{"/healthz"},
+		Output:    &out,
+	}))
+	app.Get("/healthz", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	for _, method := range []string{fiber.MethodGet, fiber.MethodHead} {
+		resp, err := app.Test(httptest.NewRequest(method, "/healthz", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	}
+
+	assert.Empty(t, out.String())
+}
+
+func Test_Fiberx_Logger_Sampling(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	app := fiber.New()
+	app.Use(Logger(LoggerConfig{
+		SampleRate: 0.25,
+		Output:     &out,
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/bad", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusBadRequest)
+	})
+
+	for i := 0; i < 8; i++ {
+		_, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, strings.Count(out.String(), "\n"))
+
+	out.Reset()
+	for i := 0; i < 3; i++ {
+		_, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/bad", nil))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, strings.Count(out.String(), "\n"))
+}
+
+func Test_Fiberx_Logger_Config(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	cfg := loggerConfigDefault(LoggerConfig{Output: &out, SampleRate: 2})
+	assert.Equal(t, &out, cfg.ErrOutput)
+	assert.Equal(t, float64(1), cfg.SampleRate)
+
+	assert.PanicsWithValue(t, "fiberx: unknown logger field non", func() {
+		Logger(LoggerConfig{Fields: []string{"non"}})
+	})
+}
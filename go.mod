@@ -3,6 +3,8 @@ module github.com/go-dawn/dawn
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/go-dawn/pkg v0.0.4-0.20201104085859-62b37379c717
 	github.com/go-playground/locales v0.13.0
 	github.com/go-playground/universal-translator v0.17.0
@@ -16,11 +18,20 @@ require (
 	github.com/kiyonlin/klog v1.1.1
 	github.com/klauspost/compress v1.11.12 // indirect
 	github.com/mattn/go-sqlite3 v1.14.6 // indirect
+	github.com/mitchellh/mapstructure v1.3.3
+	github.com/prometheus/client_golang v1.10.0
+	github.com/spf13/cast v1.3.0
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.7.0
 	github.com/valyala/bytebufferpool v1.0.0
 	github.com/valyala/fasthttp v1.22.0
-	golang.org/x/sys v0.0.0-20210309040221-94ec62e08169 // indirect
+	go.opentelemetry.io/otel v0.18.0
+	go.opentelemetry.io/otel/exporters/otlp v0.18.0
+	go.opentelemetry.io/otel/exporters/stdout v0.18.0
+	go.opentelemetry.io/otel/sdk v0.18.0
+	go.opentelemetry.io/otel/trace v0.18.0
+	golang.org/x/sys v0.0.0-20210309074719-68d13333faf2
+	gopkg.in/yaml.v2 v2.3.0
 	gorm.io/driver/mysql v1.0.4
 	gorm.io/driver/postgres v1.0.8
 	gorm.io/driver/sqlite v1.1.4
@@ -0,0 +1,147 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// StatusOK indicates the check passed
+	StatusOK = "ok"
+	// StatusFail indicates the check failed
+	StatusFail = "fail"
+)
+
+// Checker is an optional interface that a Moduler can implement
+// to report its health. Check should return as soon as ctx is done.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// Errors maps names of components, such as connections,
+// to their errors. It's used by Checker to report failures
+// of each component.
+type Errors map[string]error
+
+// Error makes it compatible with the `error` interface.
+func (e Errors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = name + ": " + e[name].Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Result is the check result of a module
+type Result struct {
+	// Status is either StatusOK or StatusFail
+	Status string `json:"status"`
+	// Error is the error message if check failed
+	Error string `json:"error,omitempty"`
+	// Components holds error messages of each failed
+	// component if the error is Errors
+	Components map[string]string `json:"components,omitempty"`
+}
+
+// Report aggregates check results of all modules
+type Report struct {
+	// Status is StatusOK only if all checks passed
+	Status string `json:"status"`
+	// Modules holds check result of each module
+	Modules map[string]Result `json:"modules,omitempty"`
+}
+
+// Check runs all checkers concurrently with the timeout
+// and reports their results.
+func Check(ctx context.Context, checkers map[string]Checker, timeout time.Duration) Report {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		mut    sync.Mutex
+		wg     sync.WaitGroup
+		report = Report{Status: StatusOK, Modules: make(map[string]Result, len(checkers))}
+	)
+
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+
+			res := check(ctx, checker)
+
+			mut.Lock()
+			defer mut.Unlock()
+
+			report.Modules[name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(name, checker)
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+// check runs the checker and gives up once ctx is done
+func check(ctx context.Context, checker Checker) Result {
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err == nil {
+		return Result{Status: StatusOK}
+	}
+
+	res := Result{Status: StatusFail, Error: err.Error()}
+	if errs, ok := err.(Errors); ok {
+		res.Components = make(map[string]string, len(errs))
+		for name, e := range errs {
+			res.Components[name] = e.Error()
+		}
+	}
+
+	return res
+}
+
+// Handler returns a handler which responses the check report
+// in json. Status code is 503 if any check fails.
+func Handler(checkers map[string]Checker, timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := Check(c.Context(), checkers, timeout)
+
+		code := fiber.StatusOK
+		if report.Status != StatusOK {
+			code = fiber.StatusServiceUnavailable
+		}
+
+		return c.Status(code).JSON(report)
+	}
+}
+
+// Live responses ok as long as the process is able to serve requests
+func Live(c *fiber.Ctx) error {
+	return c.JSON(Report{Status: StatusOK})
+}
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type checkFunc func(ctx context.Context) error
+
+func (f checkFunc) Check(ctx context.Context) error { return f(ctx) }
+
+var (
+	okChecker   = checkFunc(func(context.Context) error { return nil })
+	failChecker = checkFunc(func(context.Context) error {
+		return Errors{"b": errors.New("down"), "a": errors.New("refused")}
+	})
+	slowChecker = checkFunc(func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+)
+
+func Test_Health_Errors(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "a: refused; b: down", failChecker.Check(context.Background()).Error())
+}
+
+func Test_Health_Check(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		report := Check(context.Background(), map[string]Checker{"ok": okChecker}, time.Second)
+
+		assert.Equal(t, Report{
+			Status:  StatusOK,
+			Modules: map[string]Result{"ok": {Status: StatusOK}},
+		}, report)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		report := Check(context.Background(), map[string]Checker{
+			"ok":   okChecker,
+			"fail": failChecker,
+			"slow": slowChecker,
+		}, time.Millisecond*50)
+
+		assert.Equal(t, StatusFail, report.Status)
+		assert.Equal(t, Result{Status: StatusOK}, report.Modules["ok"])
+		assert.Equal(t, Result{
+			Status:     StatusFail,
+			Error:      "a: refused; b: down",
+			Components: map[string]string{"a": "refused", "b": "down"},
+		}, report.Modules["fail"])
+		assert.Equal(t, Result{
+			Status: StatusFail,
+			Error:  context.DeadlineExceeded.Error(),
+		}, report.Modules["slow"])
+	})
+}
+
+func Test_Health_Handler(t *testing.T) {
+	t.Parallel()
+
+	app := fiber.New()
+	app.Get("/ok", Handler(map[string]Checker{"ok": okChecker}, time.Second))
+	app.Get("/fail", Handler(map[string]Checker{"fail": failChecker}, time.Second))
+	app.Get("/live", Live)
+
+	cases := []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/ok", fiber.StatusOK, `{"status":"ok","modules":{"ok":{"status":"ok"}}}`},
+		{"/fail", fiber.StatusServiceUnavailable, `{"status":"fail","modules":{"fail":{"status":"fail","error":"a: refused; b: down","components":{"a":"refused","b":"down"}}}}`},
+		{"/live", fiber.StatusOK, `{"status":"ok"}`},
+	}
+
+	for _, tc := range cases {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, tc.target, nil))
+		require.NoError(t, err)
+		assert.Equal(t, tc.code, resp.StatusCode)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, tc.body, string(body))
+	}
+}
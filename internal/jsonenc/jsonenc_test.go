@@ -0,0 +1,24 @@
+package jsonenc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Jsonenc_AppendString(t *testing.T) {
+	for _, s := range []string{"", "plain", "q\"b\\", "n\nr\rt\t", "\x00\x1f", "中文", "bad\xff"} {
+		b := AppendString([]byte("x"), s)
+
+		var decoded string
+		assert.NoError(t, json.Unmarshal(b[1:], &decoded), s)
+		if s == "bad\xff" {
+			assert.Equal(t, "bad�", decoded)
+		} else {
+			assert.Equal(t, s, decoded)
+		}
+	}
+
+	assert.Equal(t, `"a\u0001"`, string(AppendString(nil, "a\x01")))
+}
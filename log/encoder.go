@@ -0,0 +1,205 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-dawn/dawn/internal/jsonenc"
+)
+
+// badKey is the key of values without a string key
+const badKey = "!BADKEY"
+
+// Encoder encodes an entry into a line
+type Encoder interface {
+	// Encode appends the encoded entry with a trailing newline to b
+	Encode(b []byte, e *Entry) []byte
+}
+
+// TextEncoder encodes entries in the format:
+// time LEVEL message[ logger=name] key=value ...
+// Keys and values with spaces or special characters are quoted,
+// and so are messages with special characters other than spaces,
+// so that they can't forge lines or fields.
+type TextEncoder struct{}
+
+// Encode implements Encoder
+func (TextEncoder) Encode(b []byte, e *Entry) []byte {
+	b = e.Time.AppendFormat(b, "2006/01/02 15:04:05.000")
+	b = append(b, ' ')
+	b = append(b, strings.ToUpper(e.Level.String())...)
+	b = append(b, ' ')
+	b = appendTextMessage(b, e.Message)
+
+	if e.Name != "" {
+		b = append(b, " logger="...)
+		b = appendTextString(b, e.Name)
+	}
+
+	eachField(e.Fields, func(key string, value interface{}) {
+		b = append(b, ' ')
+		b = appendTextString(b, key)
+		b = append(b, '=')
+		b = appendTextValue(b, value)
+	})
+
+	return append(b, '\n')
+}
+
+// JSONEncoder encodes entries as json objects with keys
+// time, level, msg, logger and fields.
+type JSONEncoder struct{}
+
+// Encode implements Encoder
+func (JSONEncoder) Encode(b []byte, e *Entry) []byte {
+	b = append(b, `{"time":"`...)
+	b = e.Time.AppendFormat(b, "2006-01-02T15:04:05.000Z07:00")
+	b = append(b, `","level":"`...)
+	b = append(b, e.Level.String()...)
+	b = append(b, `","msg":`...)
+	b = jsonenc.AppendString(b, e.Message)
+
+	if e.Name != "" {
+		b = append(b, `,"logger":`...)
+		b = jsonenc.AppendString(b, e.Name)
+	}
+
+	eachField(e.Fields, func(key string, value interface{}) {
+		b = append(b, ',')
+		b = jsonenc.AppendString(b, key)
+		b = append(b, ':')
+		b = appendJSONValue(b, value)
+	})
+
+	return append(b, "}\n"...)
+}
+
+// eachField calls fn with every key/value pair of fields.
+// A value without a string key is paired with badKey.
+func eachField(fields []interface{}, fn func(key string, value interface{})) {
+	for i := 0; i < len(fields); i++ {
+		key, ok := fields[i].(string)
+		if !ok || i == len(fields)-1 {
+			fn(badKey, fields[i])
+			continue
+		}
+		i++
+		fn(key, fields[i])
+	}
+}
+
+func appendTextValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return appendTextString(b, v)
+	case bool:
+		return strconv.AppendBool(b, v)
+	case int:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case uint:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(b, v, 10)
+	case float32:
+		return strconv.AppendFloat(b, float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.AppendFloat(b, v, 'g', -1, 64)
+	case time.Time:
+		return v.AppendFormat(b, time.RFC3339Nano)
+	case time.Duration:
+		return append(b, v.String()...)
+	case error:
+		return appendTextString(b, v.Error())
+	case fmt.Stringer:
+		return appendTextString(b, v.String())
+	}
+	return appendTextString(b, fmt.Sprintf("%+v", v))
+}
+
+func appendTextString(b []byte, s string) []byte {
+	if needsQuote(s) {
+		return strconv.AppendQuote(b, s)
+	}
+	return append(b, s...)
+}
+
+// appendTextMessage quotes the message like appendTextString,
+// but spaces are allowed without quoting
+func appendTextMessage(b []byte, s string) []byte {
+	if s != "" && needsQuote(strings.ReplaceAll(s, " ", "")) {
+		return strconv.AppendQuote(b, s)
+	}
+	return append(b, s...)
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+func appendJSONValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "null"...)
+	case string:
+		return jsonenc.AppendString(b, v)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return appendTextValue(b, v)
+	case float32:
+		return appendJSONFloat(b, float64(v), 32)
+	case float64:
+		return appendJSONFloat(b, v, 64)
+	case time.Time:
+		b = append(b, '"')
+		b = v.AppendFormat(b, time.RFC3339Nano)
+		return append(b, '"')
+	case time.Duration:
+		return jsonenc.AppendString(b, v.String())
+	case error:
+		return jsonenc.AppendString(b, v.Error())
+	case json.Marshaler:
+		// marshaled below
+	case fmt.Stringer:
+		return jsonenc.AppendString(b, v.String())
+	}
+
+	if data, err := json.Marshal(v); err == nil {
+		return append(b, data...)
+	}
+	return jsonenc.AppendString(b, fmt.Sprintf("%+v", v))
+}
+
+func appendJSONFloat(b []byte, f float64, bitSize int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		b = append(b, '"')
+		b = strconv.AppendFloat(b, f, 'g', -1, bitSize)
+		return append(b, '"')
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, bitSize)
+}
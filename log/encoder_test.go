@@ -0,0 +1,126 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonMarshaler struct{}
+
+func (jsonMarshaler) MarshalJSON() ([]byte, error) { return []byte(`{"a":1}`), nil }
+
+func (jsonMarshaler) String() string { return "marshaler" }
+
+var (
+	entryTime = time.Date(2021, 3, 1, 8, 30, 0, 0, time.UTC)
+	fields    = []interface{}{
+		"str", "hello world",
+		"empty", "",
+		"eq", "a=b",
+		"int", 1, "int8", int8(-8), "int16", int16(16), "int32", int32(32), "int64", int64(64),
+		"uint", uint(1), "uint8", uint8(8), "uint16", uint16(16), "uint32", uint32(32), "uint64", uint64(64),
+		"float32", float32(1.5), "float64", 2.5, "nan", math.NaN(),
+		"bool", true,
+		"at", entryTime,
+		"dur", time.Second,
+		"err", errors.New("an error"),
+		"stringer", &url.URL{Scheme: "http", Host: "dawn"},
+		"marshaler", jsonMarshaler{},
+		"slice", []int{1, 2},
+		"nil", nil,
+		1, "odd",
+	}
+)
+
+func Test_Log_TextEncoder(t *testing.T) {
+	t.Parallel()
+
+	b := TextEncoder{}.Encode(nil, &Entry{
+		Time:    entryTime,
+		Level:   InfoLevel,
+		Message: "message",
+		Name:    "name",
+		Fields:  fields,
+	})
+
+	assert.Equal(t, `2021/03/01 08:30:00.000 INFO message logger=name str="hello world" empty="" eq="a=b" `+
+		`int=1 int8=-8 int16=16 int32=32 int64=64 uint=1 uint8=8 uint16=16 uint32=32 uint64=64 `+
+		`float32=1.5 float64=2.5 nan=NaN bool=true at=2021-03-01T08:30:00Z dur=1s `+
+		`err="an error" stringer=http://dawn marshaler=marshaler slice="[1 2]" nil=<nil> `+
+		`!BADKEY=1 !BADKEY=odd`+"\n", string(b))
+}
+
+func Test_Log_TextEncoder_Quote(t *testing.T) {
+	t.Parallel()
+
+	for msg, expected := range map[string]string{
+		"":                   "",
+		"a message":          "a message",
+		"forged\nERROR line": `"forged\nERROR line"`,
+		"forged k=v":         `"forged k=v"`,
+		`say "hi"`:           `"say \"hi\""`,
+	} {
+		b := TextEncoder{}.Encode(nil, &Entry{Time: entryTime, Level: InfoLevel, Message: msg})
+		assert.Equal(t, "2021/03/01 08:30:00.000 INFO "+expected+"\n", string(b), msg)
+	}
+
+	b := TextEncoder{}.Encode(nil, &Entry{
+		Time:    entryTime,
+		Level:   InfoLevel,
+		Message: "line\nfake=1",
+		Fields:  []interface{}{"a b", 1, "k=v\n", 2},
+	})
+	assert.Equal(t, `2021/03/01 08:30:00.000 INFO "line\nfake=1" "a b"=1 "k=v\n"=2`+"\n", string(b))
+}
+
+func Test_Log_JSONEncoder(t *testing.T) {
+	t.Parallel()
+
+	b := JSONEncoder{}.Encode(nil, &Entry{
+		Time:    entryTime,
+		Level:   ErrorLevel,
+		Message: "line\n\"quoted\"\x01\xff值",
+		Name:    "name",
+		Fields:  fields,
+	})
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &entry), string(b))
+
+	for k, v := range map[string]interface{}{
+		"time":      "2021-03-01T08:30:00.000Z",
+		"level":     "error",
+		"logger":    "name",
+		"msg":       "line\n\"quoted\"\x01�值",
+		"str":       "hello world",
+		"int":       float64(1),
+		"int8":      float64(-8),
+		"uint64":    float64(64),
+		"float32":   1.5,
+		"float64":   2.5,
+		"nan":       "NaN",
+		"bool":      true,
+		"at":        "2021-03-01T08:30:00Z",
+		"dur":       "1s",
+		"err":       "an error",
+		"stringer":  "http://dawn",
+		"marshaler": map[string]interface{}{"a": float64(1)},
+		"slice":     []interface{}{float64(1), float64(2)},
+		"nil":       nil,
+		"!BADKEY":   "odd",
+	} {
+		assert.Equal(t, v, entry[k], k)
+	}
+
+	// unsupported value
+	b = JSONEncoder{}.Encode(nil, &Entry{Fields: []interface{}{"chan", make(chan int)}})
+	require.True(t, json.Valid(b), string(b))
+	assert.Contains(t, string(b), `"chan":"0x`)
+}
@@ -2,33 +2,279 @@ package log
 
 import (
 	"flag"
+	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/go-dawn/dawn"
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
 	"github.com/kiyonlin/klog"
 )
 
+// klogFlags configures klog without the global flag set
+var klogFlags = flag.NewFlagSet("klog", flag.ContinueOnError)
+
+func init() {
+	klog.InitFlags(klogFlags)
+}
+
 type Module struct {
 	dawn.Module
-	flagset *flag.FlagSet
+	adminPath string
+	closers   []io.Closer
 }
 
+// New gets the moduler. klog flags are registered into the
+// flagset if it's not nil, and it's up to the caller to parse it.
 func New(flagset *flag.FlagSet) *Module {
-	return &Module{flagset: flagset}
+	if flagset != nil {
+		InitFlags(flagset)
+	}
+	return &Module{}
 }
 
 func (m *Module) String() string {
 	return "dawn:log"
 }
 
+// Init panics if InitE fails
 func (m *Module) Init() dawn.Cleanup {
-	InitFlags(m.flagset)
+	cleanup, err := m.InitE()
+	if err != nil {
+		panic(err)
+	}
+	return cleanup
+}
+
+// InitE configures logging by config:
+//  [Log]
+//  # level of structured logging, debug, info, warn or error
+//  Level = "info"
+//  # encoding of structured logging, text or json
+//  Format = "text"
+//  # stdout, stderr or file paths, default to stderr
+//  Outputs = ["stderr"]
+//  # verbosity of Infoln and Infof
+//  V = 0
+//  # per-file verbosity of Infoln and Infof
+//  VModule = "gorm*=2,redis=1"
+//  # route to get and change levels at runtime, disabled if empty
+//  AdminPath = ""
+//  [Log.Levels]
+//  # level overrides of named loggers
+//  sql = "debug"
+//  [Log.Rotate]
+//  # rotation of file outputs, see NewRotateConfig
+//  MaxSize = 100
+// RotateFiles are reopened on SIGUSR1.
+func (m *Module) InitE() (dawn.Cleanup, error) {
+	c := config.Sub("log")
+
+	m.adminPath = c.GetString("adminPath")
 
-	flag.Parse()
+	if err := applyLevels(c); err != nil {
+		return nil, err
+	}
+
+	switch format := strings.ToLower(c.GetString("format", "text")); format {
+	case "text":
+		SetEncoder(TextEncoder{})
+	case "json":
+		SetEncoder(JSONEncoder{})
+	default:
+		return nil, fmt.Errorf("log: unknown format %s", format)
+	}
+
+	if outputs := c.GetStringSlice("outputs"); len(outputs) > 0 {
+		w, closers, err := openOutputs(outputs, c.Sub("rotate"))
+		if err != nil {
+			return nil, err
+		}
+		m.closers = closers
+		SetOutput(w)
+	}
+
+	stop := NotifyReopen()
 
 	return func() {
+		stop()
 		Flush()
+		for _, closer := range m.closers {
+			_ = closer.Close()
+		}
+		m.closers = nil
+	}, nil
+}
+
+// Reload applies levels of reloaded config
+func (m *Module) Reload() error {
+	return applyLevels(config.Sub("log"))
+}
+
+// RegisterRoutes registers the admin route if configured
+func (m *Module) RegisterRoutes(router fiber.Router) {
+	if m.adminPath == "" {
+		return
 	}
+
+	router.Get(m.adminPath, func(c *fiber.Ctx) error {
+		return fiberx.Data(c, GetLevels())
+	})
+
+	router.Put(m.adminPath, func(c *fiber.Ctx) error {
+		var req LevelsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiberx.CodeErr(fiber.StatusBadRequest, err)
+		}
+
+		if err := UpdateLevels(req); err != nil {
+			return fiberx.CodeErr(fiber.StatusBadRequest, err)
+		}
+
+		return fiberx.Data(c, GetLevels())
+	})
+}
+
+// Levels describes levels of both klog and structured logging
+type Levels struct {
+	// Level is the level of structured logging
+	Level string `json:"level"`
+	// V is the verbosity of Infoln and Infof
+	V int `json:"v"`
+	// VModule is the per-file verbosity of Infoln and Infof
+	VModule string `json:"vmodule"`
+	// Levels are level overrides of named loggers
+	Levels map[string]string `json:"levels"`
+}
+
+// LevelsRequest updates levels, nil fields are left unchanged
+type LevelsRequest struct {
+	Level   *string           `json:"level"`
+	V       *int              `json:"v"`
+	VModule *string           `json:"vmodule"`
+	Levels  map[string]string `json:"levels"`
+}
+
+// GetLevels returns current levels
+func GetLevels() Levels {
+	v, _ := strconv.Atoi(klogFlags.Lookup("v").Value.String())
+
+	levels := make(map[string]string)
+	for name, level := range std.Levels() {
+		levels[name] = level.String()
+	}
+
+	return Levels{
+		Level:   std.Level().String(),
+		V:       v,
+		VModule: klogFlags.Lookup("vmodule").Value.String(),
+		Levels:  levels,
+	}
+}
+
+// UpdateLevels validates all levels and then applies them
+func UpdateLevels(req LevelsRequest) error {
+	var (
+		level  Level
+		levels map[string]Level
+		err    error
+	)
+
+	if req.Level != nil {
+		if level, err = ParseLevel(*req.Level); err != nil {
+			return err
+		}
+	}
+
+	if req.Levels != nil {
+		if levels, err = parseLevels(req.Levels); err != nil {
+			return err
+		}
+	}
+
+	if req.V != nil {
+		if err = klogFlags.Set("v", strconv.Itoa(*req.V)); err != nil {
+			return fmt.Errorf("log: invalid v %d: %w", *req.V, err)
+		}
+	}
+
+	if req.VModule != nil {
+		if err = klogFlags.Set("vmodule", *req.VModule); err != nil {
+			return fmt.Errorf("log: invalid vmodule %s: %w", *req.VModule, err)
+		}
+	}
+
+	if req.Level != nil {
+		SetLevel(level)
+	}
+
+	if req.Levels != nil {
+		SetLevels(levels)
+	}
+
+	return nil
+}
+
+func applyLevels(c *config.Config) error {
+	level := c.GetString("level", "info")
+	v := c.GetInt("v")
+	vmodule := c.GetString("vmodule")
+
+	return UpdateLevels(LevelsRequest{
+		Level:   &level,
+		V:       &v,
+		VModule: &vmodule,
+		Levels:  c.GetStringMapString("levels"),
+	})
+}
+
+func parseLevels(m map[string]string) (map[string]Level, error) {
+	levels := make(map[string]Level, len(m))
+	for name, s := range m {
+		level, err := ParseLevel(s)
+		if err != nil {
+			return nil, err
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// openOutputs opens outputs and returns a writer writing to all of them
+func openOutputs(outputs []string, c *config.Config) (io.Writer, []io.Closer, error) {
+	var (
+		writers = make([]io.Writer, 0, len(outputs))
+		closers []io.Closer
+	)
+
+	for _, output := range outputs {
+		switch strings.ToLower(output) {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "stderr":
+			writers = append(writers, os.Stderr)
+		default:
+			f, err := NewRotateFile(NewRotateConfig(output, c))
+			if err != nil {
+				for _, closer := range closers {
+					_ = closer.Close()
+				}
+				return nil, nil, err
+			}
+			writers = append(writers, f)
+			closers = append(closers, f)
+		}
+	}
+
+	if len(writers) == 1 {
+		return writers[0], closers, nil
+	}
+
+	return io.MultiWriter(writers...), closers, nil
 }
 
 // InitFlags is for explicitly initializing the flags.
@@ -38,8 +284,16 @@ func InitFlags(flagset *flag.FlagSet) {
 }
 
 // SetOutput sets the output destination for all severities
+// and the standard structured logger
 func SetOutput(w io.Writer) {
+	// write every entry once to w only
+	_ = klogFlags.Set("logtostderr", "false")
+	_ = klogFlags.Set("alsologtostderr", "false")
+	_ = klogFlags.Set("stderrthreshold", "FATAL")
+	_ = klogFlags.Set("one_output", "true")
+
 	klog.SetOutput(w)
+	std.SetOutput(w)
 }
 
 // Flush flushes all pending log I/O.
@@ -2,9 +2,19 @@ package log
 
 import (
 	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_All(t *testing.T) {
@@ -25,3 +35,166 @@ func Test_Moduler(t *testing.T) {
 
 	m.Init()()
 }
+
+func Test_Module_Flagset(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	New(fs)
+
+	assert.NotNil(t, fs.Lookup("v"))
+	assert.NotNil(t, fs.Lookup("vmodule"))
+	assert.Nil(t, flag.CommandLine.Lookup("vmodule"))
+}
+
+func Test_Module_Config(t *testing.T) {
+	dir := tempDir(t)
+	file := filepath.Join(dir, "app.log")
+
+	config.Set("log", map[string]interface{}{
+		"level":   "warn",
+		"format":  "json",
+		"outputs": []string{file},
+		"v":       2,
+		"vmodule": "log_test=3",
+		"levels":  map[string]interface{}{"sql": "debug"},
+	})
+	defer config.Set("log", nil)
+	defer resetLevels()
+
+	cleanup, err := New(nil).InitE()
+	require.NoError(t, err)
+
+	Info("dropped")
+	Warn("kept", "k", "v")
+	Named("sql").Debug("debug of sql")
+	Errorf("%s", "klog error")
+	Infof(2, "%s", "klog info")
+
+	assert.Equal(t, Levels{
+		Level:   "warn",
+		V:       2,
+		VModule: "log_test=3",
+		Levels:  map[string]string{"sql": "debug"},
+	}, GetLevels())
+
+	cleanup()
+
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	content := string(b)
+
+	assert.NotContains(t, content, "dropped")
+	assert.Contains(t, content, `"level":"warn","msg":"kept","k":"v"}`)
+	assert.Contains(t, content, `"level":"debug","msg":"debug of sql","logger":"sql"}`)
+	assert.Equal(t, 1, strings.Count(content, "klog error"))
+	assert.Contains(t, content, "klog info")
+
+	// reload
+	config.Set("log.level", "error")
+	require.NoError(t, New(nil).Reload())
+	assert.Equal(t, "error", GetLevels().Level)
+}
+
+func Test_Module_Config_Error(t *testing.T) {
+	defer config.Set("log", nil)
+	defer resetLevels()
+
+	m := New(nil)
+
+	config.Set("log", map[string]interface{}{"level": "non"})
+	_, err := m.InitE()
+	assert.EqualError(t, err, "log: unknown level non")
+	assert.Panics(t, func() { m.Init() })
+
+	config.Set("log", map[string]interface{}{"format": "non"})
+	_, err = m.InitE()
+	assert.EqualError(t, err, "log: unknown format non")
+
+	config.Set("log", map[string]interface{}{"levels": map[string]interface{}{"sql": "non"}})
+	_, err = m.InitE()
+	assert.EqualError(t, err, "log: unknown level non")
+
+	config.Set("log", map[string]interface{}{"vmodule": "bad"})
+	_, err = m.InitE()
+	assert.Contains(t, err.Error(), "log: invalid vmodule bad")
+
+	file := filepath.Join(tempDir(t), "file")
+	require.NoError(t, ioutil.WriteFile(file, nil, 0600))
+	config.Set("log", map[string]interface{}{
+		"outputs": []string{filepath.Join(tempDir(t), "app.log"), filepath.Join(file, "app.log")},
+	})
+	_, err = m.InitE()
+	assert.Contains(t, err.Error(), "log: failed to create directory")
+}
+
+func Test_Module_Outputs(t *testing.T) {
+	w, closers, err := openOutputs([]string{"stdout"}, config.New())
+	require.NoError(t, err)
+	assert.Equal(t, os.Stdout, w)
+	assert.Empty(t, closers)
+
+	w, closers, err = openOutputs([]string{"STDOUT", "stderr"}, config.New())
+	require.NoError(t, err)
+	assert.NotEqual(t, os.Stdout, w)
+	assert.Empty(t, closers)
+}
+
+func Test_Module_Admin(t *testing.T) {
+	config.Set("log.adminPath", "/admin/log")
+	defer config.Set("log", nil)
+	defer resetLevels()
+
+	m := New(nil)
+	cleanup, err := m.InitE()
+	require.NoError(t, err)
+	defer cleanup()
+
+	app := fiber.New(fiber.Config{ErrorHandler: fiberx.ErrHandler})
+	m.RegisterRoutes(app)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/admin/log", nil))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"code":200,"data":{"level":"info","v":0,"vmodule":"","levels":{}}}`, string(body))
+
+	for _, c := range []struct {
+		body, resp string
+		code       int
+	}{
+		{`{"level":"debug","v":1,"vmodule":"log=2","levels":{"sql":"error"}}`,
+			`{"code":200,"data":{"level":"debug","v":1,"vmodule":"log=2","levels":{"sql":"error"}}}`, 200},
+		{`{"v":3}`,
+			`{"code":200,"data":{"level":"debug","v":3,"vmodule":"log=2","levels":{"sql":"error"}}}`, 200},
+		{`{"level":"non","v":5}`, `{"code":400,"message":"log: unknown level non"}`, 400},
+		{`{`, ``, 400},
+	} {
+		req := httptest.NewRequest(fiber.MethodPut, "/admin/log", strings.NewReader(c.body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, c.code, resp.StatusCode, c.body)
+		if c.resp != "" {
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, c.resp, string(body))
+		}
+	}
+
+	// admin route is disabled by default
+	config.Set("log.adminPath", nil)
+	m = New(nil)
+	_, err = m.InitE()
+	require.NoError(t, err)
+	app = fiber.New()
+	m.RegisterRoutes(app)
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/admin/log", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func resetLevels() {
+	level, v, vmodule := "info", 0, ""
+	_ = UpdateLevels(LevelsRequest{Level: &level, V: &v, VModule: &vmodule, Levels: map[string]string{}})
+	SetEncoder(TextEncoder{})
+	SetOutput(new(bytes.Buffer))
+}
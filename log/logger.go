@@ -0,0 +1,288 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Level is the severity of a structured log entry
+type Level int32
+
+const (
+	// DebugLevel logs are verbose and usually disabled in production
+	DebugLevel Level = iota - 1
+	// InfoLevel is the default logging level
+	InfoLevel
+	// WarnLevel logs are more important than Info, but don't need review
+	WarnLevel
+	// ErrorLevel logs are high-priority and need to be reviewed
+	ErrorLevel
+)
+
+// String returns the lower-case name of the level
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel parses a level name, which is case-insensitive
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return DebugLevel, nil
+	case "info", "":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("log: unknown level %s", s)
+}
+
+// Entry is a structured log record
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	// Name is the name of the logger, if any
+	Name string
+	// Fields are alternating keys and values
+	Fields []interface{}
+}
+
+// Logger writes structured entries with key/value fields.
+// Loggers derived by With and Named share output, encoder
+// and levels with their parent.
+type Logger struct {
+	core   *core
+	name   string
+	fields []interface{}
+}
+
+// core is shared by a logger and its children
+type core struct {
+	mu     sync.Mutex
+	out    io.Writer
+	enc    Encoder
+	level  int32
+	levels atomic.Value // map[string]Level
+	buf    []byte
+}
+
+// NewLogger creates a logger writing to w with the encoder.
+// The level is InfoLevel by default.
+func NewLogger(w io.Writer, enc Encoder) *Logger {
+	return &Logger{core: &core{out: w, enc: enc}}
+}
+
+var std = NewLogger(os.Stderr, TextEncoder{})
+
+// Default returns the standard logger used by package level functions
+func Default() *Logger {
+	return std
+}
+
+// With returns a child logger with fields added to every entry
+func (l *Logger) With(kv ...interface{}) *Logger {
+	if len(kv) == 0 {
+		return l
+	}
+
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+
+	return &Logger{core: l.core, name: l.name, fields: fields}
+}
+
+// Named returns a child logger with the name, whose level can be
+// overridden by SetLevels. Names of nested loggers are joined by dot.
+func (l *Logger) Named(name string) *Logger {
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	return &Logger{core: l.core, name: name, fields: l.fields}
+}
+
+// Name returns the name of the logger
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// SetOutput sets the output destination
+func (l *Logger) SetOutput(w io.Writer) {
+	l.core.mu.Lock()
+	l.core.out = w
+	l.core.mu.Unlock()
+}
+
+// SetEncoder sets the encoder of entries
+func (l *Logger) SetEncoder(enc Encoder) {
+	l.core.mu.Lock()
+	l.core.enc = enc
+	l.core.mu.Unlock()
+}
+
+// SetLevel sets the minimum level to be logged
+func (l *Logger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.core.level, int32(level))
+}
+
+// SetLevels replaces level overrides of named loggers. A logger
+// without override uses the override of its nearest parent, and
+// then the level set by SetLevel.
+func (l *Logger) SetLevels(levels map[string]Level) {
+	m := make(map[string]Level, len(levels))
+	for name, level := range levels {
+		m[name] = level
+	}
+	l.core.levels.Store(m)
+}
+
+// Levels returns level overrides of named loggers
+func (l *Logger) Levels() map[string]Level {
+	levels, _ := l.core.levels.Load().(map[string]Level)
+	m := make(map[string]Level, len(levels))
+	for name, level := range levels {
+		m[name] = level
+	}
+	return m
+}
+
+// Level returns the minimum level to be logged
+func (l *Logger) Level() Level {
+	if levels, _ := l.core.levels.Load().(map[string]Level); len(levels) > 0 {
+		for name := l.name; name != ""; {
+			if level, ok := levels[name]; ok {
+				return level
+			}
+			i := strings.LastIndexByte(name, '.')
+			if i < 0 {
+				break
+			}
+			name = name[:i]
+		}
+	}
+	return Level(atomic.LoadInt32(&l.core.level))
+}
+
+// Enabled reports whether the level is logged
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level()
+}
+
+// Debug logs a message with fields at DebugLevel
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(DebugLevel, msg, kv)
+}
+
+// Info logs a message with fields at InfoLevel
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(InfoLevel, msg, kv)
+}
+
+// Warn logs a message with fields at WarnLevel
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(WarnLevel, msg, kv)
+}
+
+// Error logs a message with fields at ErrorLevel
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(ErrorLevel, msg, kv)
+}
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	e := Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Name:    l.name,
+		Fields:  l.fields,
+	}
+	if len(kv) > 0 {
+		e.Fields = append(append(make([]interface{}, 0, len(l.fields)+len(kv)), l.fields...), kv...)
+	}
+
+	c := l.core
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buf = c.enc.Encode(c.buf[:0], &e)
+	// ignore error on purpose
+	_, _ = c.out.Write(c.buf)
+}
+
+// With returns a child logger of the standard logger
+func With(kv ...interface{}) *Logger {
+	return std.With(kv...)
+}
+
+// Named returns a named child logger of the standard logger
+func Named(name string) *Logger {
+	return std.Named(name)
+}
+
+// FromContext returns a child logger of the standard logger with
+// the request id set by requestid middleware
+func FromContext(c *fiber.Ctx) *Logger {
+	if id := c.Response().Header.Peek(fiber.HeaderXRequestID); len(id) > 0 {
+		return std.With("request_id", string(id))
+	}
+	return std
+}
+
+// SetLevel sets the minimum level of the standard logger
+func SetLevel(level Level) {
+	std.SetLevel(level)
+}
+
+// SetLevels sets level overrides of named loggers
+func SetLevels(levels map[string]Level) {
+	std.SetLevels(levels)
+}
+
+// SetEncoder sets the encoder of the standard logger
+func SetEncoder(enc Encoder) {
+	std.SetEncoder(enc)
+}
+
+// Debug logs a message with fields at DebugLevel
+func Debug(msg string, kv ...interface{}) {
+	std.Debug(msg, kv...)
+}
+
+// Info logs a message with fields at InfoLevel
+func Info(msg string, kv ...interface{}) {
+	std.Info(msg, kv...)
+}
+
+// Warn logs a message with fields at WarnLevel
+func Warn(msg string, kv ...interface{}) {
+	std.Warn(msg, kv...)
+}
+
+// Error logs a message with fields at ErrorLevel
+func Error(msg string, kv ...interface{}) {
+	std.Error(msg, kv...)
+}
@@ -0,0 +1,178 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Log_Level(t *testing.T) {
+	t.Parallel()
+
+	for _, l := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel} {
+		parsed, err := ParseLevel(strings.ToUpper(l.String()))
+		require.NoError(t, err)
+		assert.Equal(t, l, parsed)
+	}
+
+	l, err := ParseLevel("warning")
+	assert.NoError(t, err)
+	assert.Equal(t, WarnLevel, l)
+
+	_, err = ParseLevel("non")
+	assert.EqualError(t, err, "log: unknown level non")
+
+	assert.Equal(t, "level(5)", Level(5).String())
+}
+
+func Test_Log_Logger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := NewLogger(&buf, TextEncoder{})
+
+	l.Debug("debug")
+	assert.Empty(t, buf.String())
+
+	l.SetLevel(DebugLevel)
+	assert.Equal(t, DebugLevel, l.Level())
+	l.Debug("debug", "k", 1)
+	assert.Contains(t, buf.String(), " DEBUG debug k=1\n")
+
+	buf.Reset()
+	child := l.With("user", "kiyon")
+	child.With().Info("info", "k", "v")
+	assert.Contains(t, buf.String(), " INFO info user=kiyon k=v\n")
+
+	buf.Reset()
+	child.SetLevel(WarnLevel)
+	l.Info("info")
+	assert.Empty(t, buf.String())
+	l.Warn("warn")
+	assert.Contains(t, buf.String(), " WARN warn\n")
+
+	buf.Reset()
+	l.SetEncoder(JSONEncoder{})
+	child.Error("error", "k", "v")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "error", entry["msg"])
+	assert.Equal(t, "kiyon", entry["user"])
+	assert.Equal(t, "v", entry["k"])
+
+	var other bytes.Buffer
+	l.SetOutput(&other)
+	child.Error("error")
+	assert.NotEmpty(t, other.String())
+}
+
+func Test_Log_Logger_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := NewLogger(&buf, JSONEncoder{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l.With("i", i).Info("concurrent", "k", "v")
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 10)
+	for _, line := range lines {
+		assert.True(t, json.Valid([]byte(line)), line)
+	}
+}
+
+func Test_Log_Structured(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(new(bytes.Buffer))
+	SetLevel(DebugLevel)
+	defer SetLevel(InfoLevel)
+	SetEncoder(TextEncoder{})
+
+	Debug("debug")
+	Info("info")
+	Warn("warn")
+	Error("error")
+	With("k", "v").Info("with")
+
+	for _, s := range []string{
+		" DEBUG debug\n", " INFO info\n", " WARN warn\n",
+		" ERROR error\n", " INFO with k=v\n",
+	} {
+		assert.Contains(t, buf.String(), s)
+	}
+	assert.Equal(t, std, Default())
+}
+
+func Test_Log_FromContext(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(new(bytes.Buffer))
+
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		FromContext(c).Info("without id")
+		return nil
+	})
+	app.Get("/id", requestid.New(requestid.Config{
+		Generator: func() string { return "id" },
+	}), func(c *fiber.Ctx) error {
+		FromContext(c).Info("with id")
+		return nil
+	})
+
+	for _, target := range []string{"/", "/id"} {
+		_, err := app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		require.NoError(t, err)
+	}
+
+	assert.Contains(t, buf.String(), " INFO without id\n")
+	assert.Contains(t, buf.String(), " INFO with id request_id=id\n")
+}
+
+func Test_Log_Logger_Named(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := NewLogger(&buf, TextEncoder{})
+	l.SetLevels(map[string]Level{"sql": DebugLevel, "sql.slow": ErrorLevel})
+
+	sql := l.Named("sql")
+	assert.Equal(t, "sql", sql.Name())
+	sql.With("k", "v").Debug("debug")
+	assert.Contains(t, buf.String(), " DEBUG debug logger=sql k=v\n")
+
+	buf.Reset()
+	slow := sql.Named("slow")
+	assert.Equal(t, "sql.slow", slow.Name())
+	slow.Warn("warn")
+	assert.Empty(t, buf.String())
+
+	// falls back to the nearest parent
+	sql.Named("conn").Named("pool").Debug("pool")
+	assert.Contains(t, buf.String(), " DEBUG pool logger=sql.conn.pool\n")
+
+	buf.Reset()
+	l.Named("redis").Debug("debug")
+	assert.Empty(t, buf.String())
+
+	assert.Equal(t, map[string]Level{"sql": DebugLevel, "sql.slow": ErrorLevel}, l.Levels())
+}
@@ -0,0 +1,40 @@
+// +build !windows,!plan9
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// NotifyReopen reopens all RotateFiles when SIGUSR1 is
+// received, until stop is called.
+func NotifyReopen() (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	signal.Notify(ch, syscall.SIGUSR1)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := ReopenFiles(); err != nil {
+					Errorf("dawn: failed to reopen log files: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// +build !windows,!plan9
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Log_NotifyReopen(t *testing.T) {
+	name := filepath.Join(tempDir(t), "app.log")
+
+	f, err := NewRotateFile(RotateConfig{Filename: name})
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	stop := NotifyReopen()
+	defer stop()
+
+	require.NoError(t, os.Rename(name, name+".1"))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(name)
+		return err == nil
+	}, time.Second, time.Millisecond*10)
+
+	_, err = f.Write([]byte("after"))
+	require.NoError(t, err)
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "after", string(b))
+
+	stop()
+}
@@ -0,0 +1,8 @@
+// +build windows
+
+package log
+
+// NotifyReopen is a no-op since SIGUSR1 is not supported on windows
+func NotifyReopen() (stop func()) {
+	return func() {}
+}
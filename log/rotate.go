@@ -0,0 +1,431 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+)
+
+// rotateTimeFormat is the timestamp format of rotated file names
+const rotateTimeFormat = "20060102T150405.000"
+
+// RotateConfig defines the config for RotateFile.
+type RotateConfig struct {
+	// Filename is the file to write logs to. Rotated files are
+	// placed in the same directory with a timestamp appended to
+	// the base name, e.g. app-20210301T083000.000.log, and files
+	// rotated in the same millisecond get a sequence number like
+	// app-20210301T083000.000-1.log
+	Filename string
+
+	// MaxSize is the maximum size in bytes of the file before it
+	// gets rotated. Zero means no size based rotation.
+	MaxSize int64
+
+	// Interval rotates the file when it has been opened across
+	// a boundary of the interval, e.g. 24h rotates daily at
+	// midnight UTC. Zero means no time based rotation.
+	Interval time.Duration
+
+	// MaxFiles is the maximum number of rotated files to retain.
+	// Zero means retaining all.
+	MaxFiles int
+
+	// MaxAge is the maximum age of rotated files to retain.
+	// Zero means retaining all.
+	MaxAge time.Duration
+
+	// Compress determines if rotated files are compressed by gzip
+	Compress bool
+}
+
+// NewRotateConfig creates a RotateConfig for filename by config:
+//  MaxSize = 100 # megabytes
+//  Interval = "24h"
+//  MaxFiles = 10
+//  MaxAge = "168h"
+//  Compress = false
+func NewRotateConfig(filename string, c *config.Config) RotateConfig {
+	return RotateConfig{
+		Filename: filename,
+		MaxSize:  c.GetInt64("maxSize") * 1024 * 1024,
+		Interval: c.GetDuration("interval"),
+		MaxFiles: c.GetInt("maxFiles"),
+		MaxAge:   c.GetDuration("maxAge"),
+		Compress: c.GetBool("compress"),
+	}
+}
+
+// RotateFile is an io.WriteCloser which writes to a file and
+// rotates it by size or time. Rotated files are cleaned up by
+// count and age. RotateFile is safe for concurrent use.
+type RotateFile struct {
+	cfg RotateConfig
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+
+	// millMu serializes compressing and cleaning up rotated files
+	millMu sync.Mutex
+	millWg sync.WaitGroup
+}
+
+var (
+	rotateFilesMu sync.Mutex
+	rotateFiles   = make(map[*RotateFile]struct{})
+)
+
+// NewRotateFile opens the file for appending, creating
+// it and its directory if necessary.
+func NewRotateFile(cfg RotateConfig) (*RotateFile, error) {
+	f := &RotateFile{cfg: cfg}
+	f.cfg.Filename = filepath.Clean(cfg.Filename)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.open(f.cfg.Filename); err != nil {
+		return nil, err
+	}
+
+	rotateFilesMu.Lock()
+	rotateFiles[f] = struct{}{}
+	rotateFilesMu.Unlock()
+
+	return f, nil
+}
+
+// Name returns the file name
+func (f *RotateFile) Name() string {
+	return f.cfg.Filename
+}
+
+// Write writes p to the file, rotating it first if p
+// would exceed MaxSize or an Interval boundary is crossed.
+// If rotating fails, p is still written to the current file
+// with the error returned, and the next write tries again.
+func (f *RotateFile) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	var rotateErr error
+	if f.shouldRotate(int64(len(p)), time.Now()) {
+		rotateErr = f.rotate()
+	}
+
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+
+	if err == nil {
+		err = rotateErr
+	}
+
+	return
+}
+
+func (f *RotateFile) shouldRotate(n int64, now time.Time) bool {
+	if f.cfg.MaxSize > 0 && f.size > 0 && f.size+n > f.cfg.MaxSize {
+		return true
+	}
+
+	if i := f.cfg.Interval; i > 0 && !now.Truncate(i).Equal(f.openedAt.Truncate(i)) {
+		return true
+	}
+
+	return false
+}
+
+// Rotate closes the file, renames it with a timestamp
+// and opens a new one.
+func (f *RotateFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	return f.rotate()
+}
+
+// Reopen opens the file by name again and closes the former
+// one. It's useful after the file is moved by an external tool
+// like logrotate. The former one is kept if it fails to open.
+func (f *RotateFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	old := f.file
+	if err := f.open(f.cfg.Filename); err != nil {
+		return err
+	}
+
+	if err := old.Close(); err != nil {
+		return fmt.Errorf("log: failed to close %s: %w", f.cfg.Filename, err)
+	}
+
+	return nil
+}
+
+// Close closes the file and waits for background
+// compression and cleanup to be done.
+func (f *RotateFile) Close() (err error) {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		err = f.file.Close()
+	}
+	f.mu.Unlock()
+
+	rotateFilesMu.Lock()
+	delete(rotateFiles, f)
+	rotateFilesMu.Unlock()
+
+	f.millWg.Wait()
+
+	return
+}
+
+// open opens the file by name for appending, f is
+// only changed if it succeeds
+func (f *RotateFile) open(name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("log: failed to create directory of %s: %w", name, err)
+	}
+
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("log: failed to open %s: %w", name, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("log: failed to stat %s: %w", name, err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = info.ModTime()
+	if f.size == 0 {
+		f.openedAt = time.Now()
+	}
+
+	return nil
+}
+
+// osRename is replaced in tests
+var osRename = os.Rename
+
+// rotate renames the file and opens a new one. The file is
+// closed before renaming, which is required on windows, so
+// a file is opened again on failure to keep writes going.
+func (f *RotateFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return f.reopen(f.cfg.Filename, fmt.Errorf("log: failed to close %s: %w", f.cfg.Filename, err))
+	}
+
+	prefix, ext := f.prefixAndExt()
+	ts := time.Now().Format(rotateTimeFormat)
+	rotated := prefix + ts + ext
+	// never overwrite a file rotated in the same millisecond
+	for seq := 1; exists(rotated) || exists(rotated+".gz"); seq++ {
+		rotated = prefix + ts + "-" + strconv.Itoa(seq) + ext
+	}
+	if err := osRename(f.cfg.Filename, rotated); err != nil && !os.IsNotExist(err) {
+		return f.reopen(f.cfg.Filename, fmt.Errorf("log: failed to rename %s: %w", f.cfg.Filename, err))
+	}
+
+	if err := f.open(f.cfg.Filename); err != nil {
+		// keep writing to the renamed one
+		return f.reopen(rotated, err)
+	}
+
+	f.millWg.Add(1)
+	go f.mill()
+
+	return nil
+}
+
+// reopen opens the file by name after rotating fails, and returns err
+func (f *RotateFile) reopen(name string, err error) error {
+	if openErr := f.open(name); openErr != nil {
+		return fmt.Errorf("%w, %v", err, openErr)
+	}
+
+	return err
+}
+
+func (f *RotateFile) prefixAndExt() (string, string) {
+	ext := filepath.Ext(f.cfg.Filename)
+	return strings.TrimSuffix(f.cfg.Filename, ext) + "-", ext
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// rotatedFile is a rotated file with the timestamp
+// and sequence number in its name
+type rotatedFile struct {
+	path string
+	at   time.Time
+	seq  int
+}
+
+// mill compresses rotated files and removes expired ones
+func (f *RotateFile) mill() {
+	defer f.millWg.Done()
+
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	files := f.rotatedFiles()
+
+	var remove []rotatedFile
+	if f.cfg.MaxFiles > 0 && len(files) > f.cfg.MaxFiles {
+		remove = append(remove, files[f.cfg.MaxFiles:]...)
+		files = files[:f.cfg.MaxFiles]
+	}
+
+	if f.cfg.MaxAge > 0 {
+		cutoff := time.Now().Add(-f.cfg.MaxAge)
+		kept := files[:0]
+		for _, rf := range files {
+			if rf.at.Before(cutoff) {
+				remove = append(remove, rf)
+			} else {
+				kept = append(kept, rf)
+			}
+		}
+		files = kept
+	}
+
+	for _, rf := range remove {
+		_ = os.Remove(rf.path)
+	}
+
+	if !f.cfg.Compress {
+		return
+	}
+
+	for _, rf := range files {
+		if !strings.HasSuffix(rf.path, ".gz") {
+			_ = compress(rf.path)
+		}
+	}
+}
+
+// rotatedFiles returns rotated files sorted by time, newest first
+func (f *RotateFile) rotatedFiles() []rotatedFile {
+	prefix, ext := f.prefixAndExt()
+
+	infos, err := ioutil.ReadDir(filepath.Dir(f.cfg.Filename))
+	if err != nil {
+		return nil
+	}
+
+	base := filepath.Base(prefix)
+	var files []rotatedFile
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(strings.TrimSuffix(name[len(base):], ".gz"), ext)
+		seq := 0
+		if i := strings.LastIndexByte(ts, '-'); i >= 0 {
+			if seq, err = strconv.Atoi(ts[i+1:]); err != nil || seq <= 0 {
+				continue
+			}
+			ts = ts[:i]
+		}
+		at, err := time.ParseInLocation(rotateTimeFormat, ts, time.Local)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, rotatedFile{filepath.Join(filepath.Dir(prefix), name), at, seq})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].at.Equal(files[j].at) {
+			return files[i].seq > files[j].seq
+		}
+		return files[i].at.After(files[j].at)
+	})
+
+	return files
+}
+
+// compress gzips the file and removes the original one
+func compress(name string) (err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		_ = os.Remove(name + ".gz")
+		return
+	}
+
+	return os.Remove(name)
+}
+
+// ReopenFiles reopens all open RotateFiles and
+// returns the first error encountered.
+func ReopenFiles() (err error) {
+	rotateFilesMu.Lock()
+	files := make([]*RotateFile, 0, len(rotateFiles))
+	for f := range rotateFiles {
+		files = append(files, f)
+	}
+	rotateFilesMu.Unlock()
+
+	for _, f := range files {
+		// f may be closed meanwhile
+		if e := f.Reopen(); e != nil && e != os.ErrClosed && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
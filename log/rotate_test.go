@@ -0,0 +1,272 @@
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Log_NewRotateConfig(t *testing.T) {
+	t.Parallel()
+
+	c := config.New()
+	c.Set("maxSize", 10)
+	c.Set("interval", "1h")
+	c.Set("maxFiles", 3)
+	c.Set("maxAge", "24h")
+	c.Set("compress", true)
+
+	assert.Equal(t, RotateConfig{
+		Filename: "app.log",
+		MaxSize:  10 * 1024 * 1024,
+		Interval: time.Hour,
+		MaxFiles: 3,
+		MaxAge:   time.Hour * 24,
+		Compress: true,
+	}, NewRotateConfig("app.log", c))
+}
+
+func Test_Log_RotateFile_Size(t *testing.T) {
+	t.Parallel()
+
+	dir := tempDir(t)
+	name := filepath.Join(dir, "sub", "app.log")
+
+	f, err := NewRotateFile(RotateConfig{Filename: name, MaxSize: 10, MaxFiles: 2})
+	require.NoError(t, err)
+	assert.Equal(t, name, f.Name())
+
+	for _, s := range []string{"12345", "67890", "abcde", "fghij", "klmno"} {
+		_, err = f.Write([]byte(s))
+		require.NoError(t, err)
+		// make rotated file names distinct
+		time.Sleep(time.Millisecond * 2)
+	}
+	require.NoError(t, f.Close())
+
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "klmno", string(b))
+
+	rotated := f.rotatedFiles()
+	require.Len(t, rotated, 2)
+
+	b, err = ioutil.ReadFile(rotated[0].path)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefghij", string(b))
+	assert.True(t, strings.HasPrefix(filepath.Base(rotated[0].path), "app-"))
+	assert.True(t, strings.HasSuffix(rotated[0].path, ".log"))
+
+	_, err = f.Write([]byte("closed"))
+	assert.Equal(t, os.ErrClosed, err)
+	assert.Equal(t, os.ErrClosed, f.Rotate())
+	assert.Equal(t, os.ErrClosed, f.Reopen())
+	assert.NoError(t, f.Close())
+}
+
+func Test_Log_RotateFile_SameMillisecond(t *testing.T) {
+	t.Parallel()
+
+	name := filepath.Join(tempDir(t), "app.log")
+
+	f, err := NewRotateFile(RotateConfig{Filename: name, MaxSize: 1})
+	require.NoError(t, err)
+
+	// rotations in the same millisecond don't overwrite each other
+	for _, s := range []string{"1", "2", "3", "4", "5"} {
+		_, err = f.Write([]byte(s))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	rotated := f.rotatedFiles()
+	require.Len(t, rotated, 4)
+
+	var contents []string
+	for _, rf := range rotated {
+		b, err := ioutil.ReadFile(rf.path)
+		require.NoError(t, err)
+		contents = append(contents, string(b))
+	}
+	assert.Equal(t, []string{"4", "3", "2", "1"}, contents)
+}
+
+func Test_Log_RotateFile_Interval(t *testing.T) {
+	t.Parallel()
+
+	name := filepath.Join(tempDir(t), "app.log")
+
+	f, err := NewRotateFile(RotateConfig{Filename: name, Interval: time.Hour})
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	now := time.Now()
+	assert.False(t, f.shouldRotate(1, now))
+	f.openedAt = now.Add(-time.Hour)
+	assert.True(t, f.shouldRotate(1, now))
+
+	_, err = f.Write([]byte("log"))
+	require.NoError(t, err)
+	assert.Len(t, f.rotatedFiles(), 1)
+}
+
+func Test_Log_RotateFile_Compress_MaxAge(t *testing.T) {
+	t.Parallel()
+
+	dir := tempDir(t)
+	name := filepath.Join(dir, "app.log")
+
+	// an expired rotated file and an unrelated file
+	expired := filepath.Join(dir, "app-"+time.Now().Add(-time.Hour*48).Format(rotateTimeFormat)+".log")
+	require.NoError(t, ioutil.WriteFile(expired, []byte("expired"), 0600))
+	other := filepath.Join(dir, "app-other.log")
+	require.NoError(t, ioutil.WriteFile(other, []byte("other"), 0600))
+
+	f, err := NewRotateFile(RotateConfig{Filename: name, MaxAge: time.Hour * 24, Compress: true})
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("compressed"))
+	require.NoError(t, err)
+	require.NoError(t, f.Rotate())
+	require.NoError(t, f.Close())
+
+	_, err = os.Stat(expired)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(other)
+	assert.NoError(t, err)
+
+	rotated := f.rotatedFiles()
+	require.Len(t, rotated, 1)
+	assert.True(t, strings.HasSuffix(rotated[0].path, ".log.gz"))
+
+	gf, err := os.Open(rotated[0].path)
+	require.NoError(t, err)
+	defer func() { _ = gf.Close() }()
+	r, err := gzip.NewReader(gf)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "compressed", string(b))
+}
+
+func Test_Log_RotateFile_Reopen(t *testing.T) {
+	t.Parallel()
+
+	dir := tempDir(t)
+	name := filepath.Join(dir, "app.log")
+
+	f, err := NewRotateFile(RotateConfig{Filename: name})
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write([]byte("before"))
+	require.NoError(t, err)
+
+	// moved by logrotate
+	require.NoError(t, os.Rename(name, name+".1"))
+	require.NoError(t, ReopenFiles())
+
+	_, err = f.Write([]byte("after"))
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "after", string(b))
+
+	b, err = ioutil.ReadFile(name + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "before", string(b))
+}
+
+func Test_Log_RotateFile_Reopen_Error(t *testing.T) {
+	t.Parallel()
+
+	dir := tempDir(t)
+	name := filepath.Join(dir, "sub", "app.log")
+
+	f, err := NewRotateFile(RotateConfig{Filename: name})
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write([]byte("before"))
+	require.NoError(t, err)
+
+	// the directory can't be created again
+	require.NoError(t, os.Rename(filepath.Join(dir, "sub"), filepath.Join(dir, "moved")))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub"), nil, 0600))
+	assert.Error(t, f.Reopen())
+
+	// the former file is kept
+	_, err = f.Write([]byte(" after"))
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "moved", "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "before after", string(b))
+}
+
+func Test_Log_RotateFile_RenameError(t *testing.T) {
+	// not parallel since osRename is replaced
+	osRename = func(string, string) error { return os.ErrPermission }
+	defer func() { osRename = os.Rename }()
+
+	dir := tempDir(t)
+	name := filepath.Join(dir, "app.log")
+
+	f, err := NewRotateFile(RotateConfig{Filename: name, MaxSize: 10})
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write([]byte("0123456789"))
+	require.NoError(t, err)
+
+	// written to the original file anyway
+	n, err := f.Write([]byte("a"))
+	assert.Equal(t, 1, n)
+	assert.Contains(t, err.Error(), "log: failed to rename")
+	_, err = f.Write([]byte("b"))
+	assert.Error(t, err)
+
+	// rotated once renaming works again
+	osRename = os.Rename
+	_, err = f.Write([]byte("c"))
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "c", string(b))
+
+	rotated := f.rotatedFiles()
+	require.Len(t, rotated, 1)
+	b, err = ioutil.ReadFile(rotated[0].path)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789ab", string(b))
+}
+
+func Test_Log_RotateFile_Error(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewRotateFile(RotateConfig{Filename: tempDir(t)})
+	assert.Contains(t, err.Error(), "log: failed to open")
+
+	file := filepath.Join(tempDir(t), "file")
+	require.NoError(t, ioutil.WriteFile(file, nil, 0600))
+	_, err = NewRotateFile(RotateConfig{Filename: filepath.Join(file, "app.log")})
+	assert.Contains(t, err.Error(), "log: failed to create directory")
+}
+
+// tempDir works like t.TempDir which is not available before go1.15
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dawn")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
@@ -0,0 +1,355 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-dawn/dawn"
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/db/redis"
+	"github.com/go-dawn/dawn/db/sql"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"gorm.io/gorm"
+)
+
+const (
+	namespace = "dawn"
+	unmatched = "unmatched"
+	startKey  = "dawn:metrics:start"
+)
+
+var (
+	m        = &Module{}
+	hookOnce sync.Once
+)
+
+type Module struct {
+	dawn.Module
+	registry *prometheus.Registry
+	path     string
+
+	httpRequests *prometheus.CounterVec
+	httpLatency  *prometheus.HistogramVec
+	sqlLatency   *prometheus.HistogramVec
+	redisLatency *prometheus.HistogramVec
+}
+
+// New gets the moduler
+func New() *Module {
+	return m
+}
+
+// String is module name
+func (*Module) String() string {
+	return "dawn:metrics"
+}
+
+// Init creates collectors by config:
+//  [Metrics]
+//  Path = "/metrics"
+//  Buckets = [0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10]
+// Connections of dawn:sql and dawn:redis are instrumented
+// if these modules are used.
+func (m *Module) Init() dawn.Cleanup {
+	c := config.Sub("metrics")
+
+	m.path = c.GetString("path", "/metrics")
+
+	buckets := prometheus.DefBuckets
+	switch bs := c.Get("buckets").(type) {
+	case []float64:
+		buckets = bs
+	case []interface{}:
+		buckets = make([]float64, 0, len(bs))
+		for _, b := range bs {
+			if f, err := strconv.ParseFloat(fmt.Sprint(b), 64); err == nil {
+				buckets = append(buckets, f)
+			}
+		}
+	}
+
+	m.httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	m.httpLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latencies in seconds by method, route and status.",
+		Buckets:   buckets,
+	}, []string{"method", "route", "status"})
+
+	m.sqlLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sql",
+		Name:      "query_duration_seconds",
+		Help:      "SQL query latencies in seconds by connection, operation and status.",
+		Buckets:   buckets,
+	}, []string{"connection", "operation", "status"})
+
+	m.redisLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis command latencies in seconds by connection, command and status.",
+		Buckets:   buckets,
+	}, []string{"connection", "command", "status"})
+
+	m.registry = prometheus.NewRegistry()
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpLatency, m.sqlLatency, m.redisLatency,
+		poolCollector{},
+	)
+
+	return nil
+}
+
+// BootE instruments connections of dawn:sql and dawn:redis
+func (m *Module) BootE() (err error) {
+	hookOnce.Do(func() {
+		redis.OnConnect(instrumentRedis)
+		err = sql.OnConnect(instrumentSql)
+	})
+
+	return
+}
+
+// RegisterRoutes registers the metrics route
+func (m *Module) RegisterRoutes(router fiber.Router) {
+	h := fasthttpadaptor.NewFastHTTPHandler(
+		promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}),
+	)
+
+	router.Get(m.path, func(c *fiber.Ctx) error {
+		h(c.Context())
+		return nil
+	})
+}
+
+// Registerer returns the registerer for custom collectors,
+// or nil before the module is initialized
+func Registerer() prometheus.Registerer {
+	if m.registry == nil {
+		return nil
+	}
+	return m.registry
+}
+
+// Middleware records count and latency of requests labelled by
+// method, route pattern and status code. It should be used
+// alongside fiberx.Logger and be the last middleware, so that
+// requests not matching any route are labelled as unmatched.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		start := time.Now()
+		self := c.Route()
+
+		err = c.Next()
+
+		if m.httpRequests == nil {
+			return
+		}
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		path := c.Route().Path
+		// no route matched after this middleware
+		if c.Route() == self && status == fiber.StatusNotFound {
+			path = unmatched
+		}
+
+		labels := prometheus.Labels{
+			"method": c.Method(),
+			"route":  path,
+			"status": strconv.Itoa(status),
+		}
+		m.httpRequests.With(labels).Inc()
+		m.httpLatency.With(labels).Observe(time.Since(start).Seconds())
+
+		return
+	}
+}
+
+// instrumentSql registers gorm callbacks to time every operation
+func instrumentSql(name string, db *gorm.DB) error {
+	before := func(db *gorm.DB) {
+		db.InstanceSet(startKey, time.Now())
+	}
+
+	after := func(op string) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			v, ok := db.InstanceGet(startKey)
+			if !ok || m.sqlLatency == nil {
+				return
+			}
+
+			status := "ok"
+			if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+				status = "error"
+			}
+
+			m.sqlLatency.WithLabelValues(name, op, status).
+				Observe(time.Since(v.(time.Time)).Seconds())
+		}
+	}
+
+	cb := db.Callback()
+
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("dawn:metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("dawn:metrics:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("dawn:metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("dawn:metrics:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("dawn:metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("dawn:metrics:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("dawn:metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("dawn:metrics:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("dawn:metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("dawn:metrics:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("dawn:metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("dawn:metrics:after_raw", after("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// instrumentRedis adds a hook to time every command
+func instrumentRedis(name string, client *goredis.Client) {
+	client.AddHook(redisHook{name})
+}
+
+type redisStartKey struct{}
+
+type redisHook struct {
+	name string
+}
+
+func (h redisHook) BeforeProcess(ctx context.Context, _ goredis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (h redisHook) AfterProcess(ctx context.Context, cmd goredis.Cmder) error {
+	h.observe(ctx, cmd.Name(), cmd.Err())
+	return nil
+}
+
+func (h redisHook) BeforeProcessPipeline(ctx context.Context, _ []goredis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (h redisHook) AfterProcessPipeline(ctx context.Context, cmds []goredis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if e := cmd.Err(); e != nil && e != goredis.Nil {
+			err = e
+			break
+		}
+	}
+	h.observe(ctx, "pipeline", err)
+	return nil
+}
+
+func (h redisHook) observe(ctx context.Context, command string, err error) {
+	start, ok := ctx.Value(redisStartKey{}).(time.Time)
+	if !ok || m.redisLatency == nil {
+		return
+	}
+
+	status := "ok"
+	if err != nil && err != goredis.Nil {
+		status = "error"
+	}
+
+	m.redisLatency.WithLabelValues(h.name, command, status).
+		Observe(time.Since(start).Seconds())
+}
+
+var (
+	sqlPoolDesc = func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "sql_pool", name),
+			help, []string{"connection"}, nil)
+	}
+	redisPoolDesc = func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", name),
+			help, []string{"connection"}, nil)
+	}
+
+	sqlMaxOpen     = sqlPoolDesc("max_open_connections", "Maximum number of open connections.")
+	sqlOpen        = sqlPoolDesc("open_connections", "Number of established connections.")
+	sqlInUse       = sqlPoolDesc("in_use_connections", "Number of connections currently in use.")
+	sqlIdle        = sqlPoolDesc("idle_connections", "Number of idle connections.")
+	sqlWaitCount   = sqlPoolDesc("wait_count_total", "Total number of connections waited for.")
+	sqlWaitSeconds = sqlPoolDesc("wait_duration_seconds_total", "Total time blocked waiting for a new connection.")
+
+	redisHits     = redisPoolDesc("hits_total", "Number of times free connection was found in the pool.")
+	redisMisses   = redisPoolDesc("misses_total", "Number of times free connection was not found in the pool.")
+	redisTimeouts = redisPoolDesc("timeouts_total", "Number of times a wait timeout occurred.")
+	redisTotal    = redisPoolDesc("total_connections", "Number of total connections in the pool.")
+	redisIdle     = redisPoolDesc("idle_connections", "Number of idle connections in the pool.")
+	redisStale    = redisPoolDesc("stale_connections", "Number of stale connections removed from the pool.")
+)
+
+// poolCollector collects pool stats of every sql and redis connection
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		sqlMaxOpen, sqlOpen, sqlInUse, sqlIdle, sqlWaitCount, sqlWaitSeconds,
+		redisHits, redisMisses, redisTimeouts, redisTotal, redisIdle, redisStale,
+	} {
+		ch <- d
+	}
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	gauge, counter := prometheus.GaugeValue, prometheus.CounterValue
+
+	for name, gdb := range sql.Conns() {
+		db, err := gdb.DB()
+		if err != nil {
+			continue
+		}
+
+		s := db.Stats()
+		ch <- prometheus.MustNewConstMetric(sqlMaxOpen, gauge, float64(s.MaxOpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(sqlOpen, gauge, float64(s.OpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(sqlInUse, gauge, float64(s.InUse), name)
+		ch <- prometheus.MustNewConstMetric(sqlIdle, gauge, float64(s.Idle), name)
+		ch <- prometheus.MustNewConstMetric(sqlWaitCount, counter, float64(s.WaitCount), name)
+		ch <- prometheus.MustNewConstMetric(sqlWaitSeconds, counter, s.WaitDuration.Seconds(), name)
+	}
+
+	for name, client := range redis.Conns() {
+		s := client.PoolStats()
+		ch <- prometheus.MustNewConstMetric(redisHits, counter, float64(s.Hits), name)
+		ch <- prometheus.MustNewConstMetric(redisMisses, counter, float64(s.Misses), name)
+		ch <- prometheus.MustNewConstMetric(redisTimeouts, counter, float64(s.Timeouts), name)
+		ch <- prometheus.MustNewConstMetric(redisTotal, gauge, float64(s.TotalConns), name)
+		ch <- prometheus.MustNewConstMetric(redisIdle, gauge, float64(s.IdleConns), name)
+		ch <- prometheus.MustNewConstMetric(redisStale, counter, float64(s.StaleConns), name)
+	}
+}
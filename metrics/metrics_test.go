@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/db/sql"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Metrics_Module_Name(t *testing.T) {
+	assert.Equal(t, "dawn:metrics", New().String())
+}
+
+func Test_Metrics_Module(t *testing.T) {
+	config.Set("metrics.buckets", []float64{0.1, 1})
+
+	m := New()
+	m.Init()
+	require.NoError(t, m.BootE())
+
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		return c.SendString("user")
+	})
+	app.Get("/error", func(c *fiber.Ctx) error {
+		return errors.New("error")
+	})
+	app.Get("/fiber-error", func(c *fiber.Ctx) error {
+		return fiber.ErrBadRequest
+	})
+	m.RegisterRoutes(app)
+
+	for _, target := range []string{"/users/1", "/users/2", "/error", "/fiber-error", "/non"} {
+		_, err := app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		require.NoError(t, err)
+	}
+
+	// sql
+	sqlm := sql.New()
+	cleanup, err := sqlm.InitE()
+	require.NoError(t, err)
+	defer cleanup()
+	require.NoError(t, sql.Conn().Exec("SELECT 1").Error)
+
+	// redis
+	client := goredis.NewClient(&goredis.Options{Addr: "127.0.0.1:99999", MaxRetries: -1})
+	instrumentRedis("default", client)
+	_ = client.Get(context.Background(), "key").Err()
+	_, _ = client.Pipelined(context.Background(), func(p goredis.Pipeliner) error {
+		p.Get(context.Background(), "key")
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/metrics", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, s := range []string{
+		`dawn_http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`dawn_http_requests_total{method="GET",route="/error",status="500"} 1`,
+		`dawn_http_requests_total{method="GET",route="/fiber-error",status="400"} 1`,
+		`dawn_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`dawn_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="0.1"} 2`,
+		`dawn_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="1"} 2`,
+		`dawn_sql_query_duration_seconds_count{connection="testing",operation="raw",status="ok"} 1`,
+		`dawn_sql_pool_open_connections{connection="testing"}`,
+		`dawn_redis_command_duration_seconds_count{command="get",connection="default",status="error"} 1`,
+		`dawn_redis_command_duration_seconds_count{command="pipeline",connection="default",status="error"} 1`,
+		`go_goroutines`,
+	} {
+		assert.Contains(t, string(body), s)
+	}
+
+	// custom buckets
+	assert.NotContains(t, string(body), `le="0.005"`)
+
+	assert.NoError(t, Registerer().Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "custom"})))
+}
+
+func Test_Metrics_Middleware_Without_Init(t *testing.T) {
+	m := &Module{}
+	old := New()
+	defer func() { *New() = *old }()
+	*New() = *m
+
+	// a nil interface rather than a nil registry
+	assert.True(t, Registerer() == nil)
+
+	app := fiber.New()
+	app.Use(Middleware())
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
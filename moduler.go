@@ -1,7 +1,9 @@
 package dawn
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -25,6 +27,78 @@ type Moduler interface {
 	RegisterRoutes(fiber.Router)
 }
 
+// Dependent is an optional interface that a Moduler can implement
+// to declare the modules it depends on. Dependencies are referred by
+// their names, which are the results of String method. Sloop makes sure
+// dependencies are initialized and booted before the dependent module.
+type Dependent interface {
+	// Dependencies returns names of the modules depended on
+	Dependencies() []string
+}
+
+// Initializer is an optional interface that a Moduler can implement
+// to report initialization failure instead of panicking. If implemented,
+// Sloop calls InitE instead of Init. The returned cleanup function is
+// still registered when an error occurs, so that resources which were
+// partially acquired can be released.
+type Initializer interface {
+	InitE() (Cleanup, error)
+}
+
+// Booter is an optional interface that a Moduler can implement
+// to report boot failure instead of panicking. If implemented,
+// Sloop calls BootE instead of Boot.
+type Booter interface {
+	BootE() error
+}
+
+// Stopper is an optional interface that a Moduler can implement to
+// release resources gracefully. If implemented, Stop is called on
+// cleanup instead of the cleanup function returned by Init, and ctx
+// carries the remaining budget of shutdown.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// Reloader is an optional interface that a Moduler can implement to
+// apply configuration changes without restart. Reload is called in
+// initialization order after configuration is reloaded.
+type Reloader interface {
+	Reload() error
+}
+
+// ModuleError records a failure of a module during setup
+type ModuleError struct {
+	// Module is the name of the failed module
+	Module string
+	// Op is the failed operation, "init", "boot" or "reload"
+	Op string
+	// Err is the underlying error
+	Err error
+}
+
+// Error makes it compatible with the `error` interface.
+func (e *ModuleError) Error() string {
+	return e.Module + " " + e.Op + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ModuleError) Unwrap() error {
+	return e.Err
+}
+
+// SetupError aggregates all module errors occurred during setup
+type SetupError []*ModuleError
+
+// Error makes it compatible with the `error` interface.
+func (e SetupError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "dawn: failed to setup modules: " + strings.Join(msgs, "; ")
+}
+
 // Module is an empty struct implements Moduler interface
 // and can be embedded into custom struct as a Moduler
 type Module struct{}
@@ -40,3 +114,137 @@ func (Module) Boot() {}
 
 // RegisterRoutes add routes to fiber router
 func (Module) RegisterRoutes(fiber.Router) {}
+
+// initModule initializes the module and converts panic to error
+func initModule(mod Moduler) (cleanup Cleanup, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if i, ok := mod.(Initializer); ok {
+		return i.InitE()
+	}
+
+	return mod.Init(), nil
+}
+
+// bootModule boots the module and converts panic to error
+func bootModule(mod Moduler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if b, ok := mod.(Booter); ok {
+		return b.BootE()
+	}
+
+	mod.Boot()
+
+	return nil
+}
+
+// reloadModule reloads the module and converts panic to error
+func reloadModule(mod Moduler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if r, ok := mod.(Reloader); ok {
+		return r.Reload()
+	}
+
+	return nil
+}
+
+// failedDependency returns the first dependency of the module
+// which is marked as failed
+func failedDependency(mod Moduler, failed map[string]bool) string {
+	if d, ok := mod.(Dependent); ok {
+		for _, dep := range d.Dependencies() {
+			if failed[dep] {
+				return dep
+			}
+		}
+	}
+	return ""
+}
+
+// sortModulers sorts modulers topologically by their dependencies.
+// Modulers without dependency relationship keep the order they were added.
+func sortModulers(mods []Moduler) ([]Moduler, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		indexes = make(map[string][]int, len(mods))
+		states  = make([]int, len(mods))
+		sorted  = make([]Moduler, 0, len(mods))
+		path    []string
+		visit   func(i int) error
+	)
+
+	for i, mod := range mods {
+		name := mod.String()
+		indexes[name] = append(indexes[name], i)
+	}
+
+	visit = func(i int) error {
+		name := mods[i].String()
+
+		switch states[i] {
+		case visited:
+			return nil
+		case visiting:
+			// find where the cycle begins
+			start := 0
+			for j := len(path) - 1; j >= 0; j-- {
+				if path[j] == name {
+					start = j
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), name)
+			return fmt.Errorf("dawn: module dependency cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+
+		states[i] = visiting
+		path = append(path, name)
+
+		if d, ok := mods[i].(Dependent); ok {
+			for _, dep := range d.Dependencies() {
+				js, ok := indexes[dep]
+				if !ok {
+					return fmt.Errorf("dawn: module %s depends on unknown module %s", name, dep)
+				}
+				for _, j := range js {
+					if err := visit(j); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		states[i] = visited
+		sorted = append(sorted, mods[i])
+
+		return nil
+	}
+
+	for i := range mods {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockModule struct {
@@ -26,3 +27,81 @@ func Test_Moduler_Embed_Empty_Module(t *testing.T) {
 
 	module.RegisterRoutes(nil)
 }
+
+type depModule struct {
+	Module
+	name string
+	deps []string
+	logs *[]string
+}
+
+func (m depModule) String() string { return m.name }
+
+func (m depModule) Dependencies() []string { return m.deps }
+
+func (m depModule) Init() Cleanup {
+	*m.logs = append(*m.logs, "init "+m.name)
+	return func() { *m.logs = append(*m.logs, "cleanup "+m.name) }
+}
+
+func (m depModule) Boot() {
+	*m.logs = append(*m.logs, "boot "+m.name)
+}
+
+// go test -run Test_Moduler_Sort -race
+func Test_Moduler_Sort(t *testing.T) {
+	t.Parallel()
+
+	names := func(mods []Moduler) (res []string) {
+		for _, mod := range mods {
+			res = append(res, mod.String())
+		}
+		return
+	}
+
+	t.Run("keep order", func(t *testing.T) {
+		mods, err := sortModulers([]Moduler{
+			depModule{name: "a"}, depModule{name: "b"}, depModule{name: "c"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, names(mods))
+	})
+
+	t.Run("dependencies first", func(t *testing.T) {
+		mods, err := sortModulers([]Moduler{
+			depModule{name: "cache", deps: []string{"redis", "log"}},
+			depModule{name: "app", deps: []string{"cache"}},
+			depModule{name: "redis", deps: []string{"log"}},
+			depModule{name: "log"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"log", "redis", "cache", "app"}, names(mods))
+	})
+
+	t.Run("unknown dependency", func(t *testing.T) {
+		_, err := sortModulers([]Moduler{
+			depModule{name: "cache", deps: []string{"redis"}},
+		})
+		require.Error(t, err)
+		assert.Equal(t, "dawn: module cache depends on unknown module redis", err.Error())
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		_, err := sortModulers([]Moduler{
+			depModule{name: "log"},
+			depModule{name: "a", deps: []string{"log", "b"}},
+			depModule{name: "b", deps: []string{"c"}},
+			depModule{name: "c", deps: []string{"a"}},
+		})
+		require.Error(t, err)
+		assert.Equal(t, "dawn: module dependency cycle detected: a -> b -> c -> a", err.Error())
+	})
+
+	t.Run("self", func(t *testing.T) {
+		_, err := sortModulers([]Moduler{
+			depModule{name: "a", deps: []string{"a"}},
+		})
+		require.Error(t, err)
+		assert.Equal(t, "dawn: module dependency cycle detected: a -> a", err.Error())
+	})
+}
@@ -1,14 +1,20 @@
 package dawn
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"net"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/go-dawn/dawn/config"
 	"github.com/go-dawn/dawn/fiberx"
+	"github.com/go-dawn/dawn/health"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/pprof"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -18,10 +24,30 @@ import (
 // Version of current dawn package
 const Version = "0.4.0"
 
+// DefaultShutdownTimeout is the default budget of draining on graceful shutdown
+const DefaultShutdownTimeout = time.Second * 10
+
+// DefaultCleanupTimeout is the default budget of cleaning up modules on shutdown
+const DefaultCleanupTimeout = time.Second * 10
+
 // Config is a struct holding the sloop settings.
 type Config struct {
 	// App indicates to fiber app instance
 	App *fiber.App
+
+	// ShutdownTimeout is the budget of draining in-flight requests
+	// on graceful shutdown. Default to DefaultShutdownTimeout
+	ShutdownTimeout time.Duration
+
+	// CleanupTimeout is the budget of cleaning up modules on graceful
+	// shutdown, which starts after draining regardless of how long it
+	// takes. Default to DefaultCleanupTimeout
+	CleanupTimeout time.Duration
+
+	// ErrorLog specifies an optional logger for errors occurred
+	// during shutdown. If nil, logging is done via the log
+	// package's standard logger.
+	ErrorLog *log.Logger
 }
 
 // Sloop denotes Dawn application
@@ -29,10 +55,18 @@ type Sloop struct {
 	// Config is the embedded config
 	Config
 
-	app      *fiber.App
-	mods     []Moduler
-	cleanups []Cleanup
-	sigCh    chan os.Signal
+	app          *fiber.App
+	mods         []Moduler
+	cleanups     []moduleCleanup
+	sigCh        chan os.Signal
+	cancelReload func()
+	ready        int32
+}
+
+// moduleCleanup is a context aware cleanup of a module
+type moduleCleanup struct {
+	name string
+	fn   func(ctx context.Context) error
 }
 
 // New returns a new Sloop with options.
@@ -92,7 +126,11 @@ func (s *Sloop) Run(addr string) error {
 		return errors.New("dawn: app is nil")
 	}
 
-	s.Setup().registerRoutes()
+	if err := s.Setup(); err != nil {
+		return err
+	}
+
+	s.registerRoutes()
 
 	return s.app.Listen(addr)
 }
@@ -105,11 +143,82 @@ func (s *Sloop) RunTls(addr, certFile, keyFile string) error {
 		return errors.New("dawn: app is nil")
 	}
 
-	s.Setup().registerRoutes()
+	if err := s.Setup(); err != nil {
+		return err
+	}
+
+	s.registerRoutes()
 
 	return s.app.ListenTLS(addr, certFile, keyFile)
 }
 
+// RunUntilSignal runs a web server until SIGTERM or SIGINT is received,
+// then shuts down gracefully within the ShutdownTimeout. SIGHUP reloads
+// configuration and keeps the server running.
+func (s *Sloop) RunUntilSignal(addr string) error {
+	return s.runUntilSignal(func() error {
+		return s.app.Listen(addr)
+	})
+}
+
+// RunTlsUntilSignal runs a tls web server until SIGTERM or SIGINT is
+// received, then shuts down gracefully within the ShutdownTimeout.
+func (s *Sloop) RunTlsUntilSignal(addr, certFile, keyFile string) error {
+	return s.runUntilSignal(func() error {
+		return s.app.ListenTLS(addr, certFile, keyFile)
+	})
+}
+
+// RunListenerUntilSignal serves on the listener until SIGTERM or SIGINT
+// is received, then shuts down gracefully within the ShutdownTimeout.
+// It's useful with a listener inherited from the daemon master.
+func (s *Sloop) RunListenerUntilSignal(ln net.Listener) error {
+	return s.runUntilSignal(func() error {
+		return s.app.Listener(ln)
+	})
+}
+
+func (s *Sloop) runUntilSignal(listen func() error) error {
+	if s.app == nil {
+		return errors.New("dawn: app is nil")
+	}
+
+	if err := s.Setup(); err != nil {
+		return err
+	}
+
+	s.registerRoutes()
+
+	signal.Notify(s.sigCh, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signal.Stop(s.sigCh)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- listen()
+	}()
+
+	for {
+		select {
+		case err := <-errCh:
+			s.Cleanup()
+			return err
+		case sig := <-s.sigCh:
+			if sig != syscall.SIGHUP {
+				s.logf("dawn: received signal %s, shutting down", sig)
+				return s.shutdownWithTimeout()
+			}
+			s.reload()
+		}
+	}
+}
+
+func (s *Sloop) shutdownWithTimeout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	defer cancel()
+
+	return s.ShutdownWithContext(ctx)
+}
+
 // Shutdown gracefully shuts down the server without interrupting any active connections.
 func (s *Sloop) Shutdown() error {
 	if s.app == nil {
@@ -118,52 +227,302 @@ func (s *Sloop) Shutdown() error {
 	return s.app.Shutdown()
 }
 
+// ShutdownWithContext stops accepting new connections and waits in-flight
+// requests to be done until ctx is done. Then modules are cleaned up within
+// the CleanupTimeout, so a slow drain doesn't leave cleanups no time.
+func (s *Sloop) ShutdownWithContext(ctx context.Context) (err error) {
+	if s.app == nil {
+		return fmt.Errorf("shutdown: fiber app is not found")
+	}
+
+	atomic.StoreInt32(&s.ready, 0)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.app.Shutdown()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("dawn: failed to drain connections: %w", ctx.Err())
+	}
+
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout())
+	defer cancel()
+
+	s.CleanupContext(cleanupCtx)
+
+	return
+}
+
 // Router returns the server router
 func (s *Sloop) Router() fiber.Router {
 	return s.app
 }
 
-// Setup initializes all modules and then boots them
-func (s *Sloop) Setup() *Sloop {
-	return s.init().boot()
+// Setup sorts all modules by their dependencies, initializes
+// them and then boots them in that order. Errors of all failed modules
+// are aggregated into a SetupError, and modules already initialized
+// are cleaned up before it returns.
+func (s *Sloop) Setup() error {
+	mods, err := sortModulers(s.mods)
+	if err != nil {
+		return err
+	}
+	s.mods = mods
+
+	if err = s.init(); err == nil {
+		err = s.boot()
+	}
+
+	if err != nil {
+		s.Cleanup()
+		return err
+	}
+
+	s.cancelReload = config.OnReload(s.reloadModules)
+
+	atomic.StoreInt32(&s.ready, 1)
+
+	return nil
+}
+
+// Reload reloads configuration and then notifies modules
+// implementing Reloader in initialization order.
+func (s *Sloop) Reload() error {
+	return config.Reload()
 }
 
-func (s *Sloop) init() *Sloop {
-	for _, mod := range s.mods {
-		if cleanup := mod.Init(); cleanup != nil {
-			s.cleanups = append(s.cleanups, cleanup)
+func (s *Sloop) reload() {
+	if err := s.Reload(); err != nil {
+		s.logf("dawn: %v", err)
+	}
+}
+
+func (s *Sloop) reloadModules() {
+	for _, err := range s.each("reload", reloadModule) {
+		s.logf("dawn: failed to reload: %v", err)
+	}
+}
+
+func (s *Sloop) init() error {
+	return s.errs(s.each("init", func(mod Moduler) error {
+		cleanup, err := initModule(mod)
+		if stopper, ok := mod.(Stopper); ok {
+			s.cleanups = append(s.cleanups, moduleCleanup{mod.String(), stopper.Stop})
+		} else if cleanup != nil {
+			s.cleanups = append(s.cleanups, moduleCleanup{mod.String(), func(context.Context) error {
+				cleanup()
+				return nil
+			}})
 		}
+		return err
+	}))
+}
+
+func (s *Sloop) boot() error {
+	return s.errs(s.each("boot", bootModule))
+}
+
+// errs converts module errors to an error
+func (s *Sloop) errs(errs SetupError) error {
+	if len(errs) > 0 {
+		return errs
 	}
-	return s
+	return nil
 }
 
-func (s *Sloop) boot() *Sloop {
+// each calls fn with every module in order and collects errors.
+// Modules whose dependencies failed are skipped.
+func (s *Sloop) each(op string, fn func(Moduler) error) SetupError {
+	var (
+		errs   SetupError
+		failed = make(map[string]bool)
+	)
+
 	for _, mod := range s.mods {
-		mod.Boot()
+		name := mod.String()
+
+		if dep := failedDependency(mod, failed); dep != "" {
+			errs = append(errs, &ModuleError{Module: name, Op: op,
+				Err: fmt.Errorf("dependency %s failed", dep)})
+			failed[name] = true
+			continue
+		}
+
+		if err := fn(mod); err != nil {
+			errs = append(errs, &ModuleError{Module: name, Op: op, Err: err})
+			failed[name] = true
+		}
 	}
 
-	return s
+	return errs
 }
 
 func (s *Sloop) registerRoutes() *Sloop {
+	s.registerHealthRoutes()
+	s.registerDebugRoutes()
+
 	for _, mod := range s.mods {
 		mod.RegisterRoutes(s.app)
 	}
 	return s
 }
 
-// Cleanup releases resources
+// registerHealthRoutes registers health, readiness and liveness
+// routes by config, each of them is disabled if its path is empty:
+//  [Health]
+//  Healthz = "/healthz"
+//  Readyz = "/readyz"
+//  Livez = "/livez"
+//  Timeout = "5s"
+// Modules implementing health.Checker are checked in healthz and readyz.
+// Readyz fails before setup is done or after shutdown begins. Errors of
+// checkers are reported without auth, so don't expose them publicly.
+func (s *Sloop) registerHealthRoutes() {
+	c := config.Sub("health")
+
+	healthz, readyz, livez := c.GetString("healthz"), c.GetString("readyz"), c.GetString("livez")
+	if healthz == "" && readyz == "" && livez == "" {
+		return
+	}
+
+	checkers := make(map[string]health.Checker)
+	for _, mod := range s.mods {
+		if checker, ok := mod.(health.Checker); ok {
+			checkers[mod.String()] = checker
+		}
+	}
+
+	check := health.Handler(checkers, c.GetDuration("timeout", time.Second*5))
+
+	if healthz != "" {
+		s.app.Get(healthz, check)
+	}
+
+	if readyz != "" {
+		s.app.Get(readyz, func(ctx *fiber.Ctx) error {
+			if atomic.LoadInt32(&s.ready) == 0 {
+				return ctx.Status(fiber.StatusServiceUnavailable).
+					JSON(health.Report{Status: health.StatusFail})
+			}
+			return check(ctx)
+		})
+	}
+
+	if livez != "" {
+		s.app.Get(livez, health.Live)
+	}
+}
+
+// registerDebugRoutes registers the route dumping the effective config
+// with secrets masked if debug is on and the route is configured:
+//  Debug = true
+//  # route dumping the effective config, disabled if empty
+//  DebugConfigPath = "/debug/config"
+// Then GET /debug/config?format=toml dumps the config in the format of
+// json, yaml or toml, default to json. Secrets are masked by their
+// placeholders and key names, see config.MaskedSettings, but the route
+// has no auth and must not be exposed publicly.
+func (s *Sloop) registerDebugRoutes() {
+	path := config.GetString("debugConfigPath")
+	if !config.GetBool("debug") || path == "" {
+		return
+	}
+
+	s.app.Get(path, func(c *fiber.Ctx) error {
+		format := c.Query("format", "json")
+
+		b, err := config.Dump(format)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		if format == "json" {
+			c.Type("json")
+		} else {
+			c.Type("txt")
+		}
+
+		return c.Send(b)
+	})
+}
+
+// Cleanup releases resources in reverse order of initialization
 func (s *Sloop) Cleanup() {
-	for _, fn := range s.cleanups {
-		fn()
+	s.CleanupContext(context.Background())
+}
+
+// CleanupContext releases resources one by one in reverse order of
+// initialization, so dependents are released before their dependencies.
+// Each module's cleanup is given ctx and won't be waited once ctx is done,
+// then the rest are skipped in order. Modules overran the deadline, skipped
+// or failed to cleanup are logged.
+func (s *Sloop) CleanupContext(ctx context.Context) {
+	if s.cancelReload != nil {
+		s.cancelReload()
+		s.cancelReload = nil
+	}
+
+	for i := len(s.cleanups) - 1; i >= 0; i-- {
+		c := s.cleanups[i]
+
+		if err := ctx.Err(); err != nil {
+			s.logf("dawn: skipped cleaning up %s: %v", c.name, err)
+			continue
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- c.fn(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				s.logf("dawn: failed to clean up %s: %v", c.name, err)
+			}
+		case <-ctx.Done():
+			s.logf("dawn: %s overran the shutdown deadline: %v", c.name, ctx.Err())
+		}
+	}
+	s.cleanups = nil
+}
+
+func (s *Sloop) shutdownTimeout() time.Duration {
+	if s.ShutdownTimeout > 0 {
+		return s.ShutdownTimeout
+	}
+	return DefaultShutdownTimeout
+}
+
+func (s *Sloop) cleanupTimeout() time.Duration {
+	if s.CleanupTimeout > 0 {
+		return s.CleanupTimeout
 	}
+	return DefaultCleanupTimeout
 }
 
-// Watch listens to signals and waits to exit
+func (s *Sloop) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// Watch listens to signals and waits to exit.
+// SIGHUP reloads configuration instead of exiting.
 func (s *Sloop) Watch() {
 	signal.Notify(s.sigCh,
 		syscall.SIGTERM, syscall.SIGINT,
 		syscall.SIGHUP, syscall.SIGQUIT)
 
-	<-s.sigCh
+	for sig := range s.sigCh {
+		if sig != syscall.SIGHUP {
+			return
+		}
+		s.reload()
+	}
 }
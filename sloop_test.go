@@ -1,7 +1,17 @@
 package dawn
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -119,3 +129,469 @@ func Test_Sloop_Watch(t *testing.T) {
 		assert.Fail(t, "should receive signal")
 	}
 }
+
+func Test_Sloop_Setup(t *testing.T) {
+	t.Parallel()
+
+	t.Run("dependency order", func(t *testing.T) {
+		var logs []string
+
+		s := New().AddModulers(
+			depModule{name: "cache", deps: []string{"redis"}, logs: &logs},
+			depModule{name: "redis", logs: &logs},
+		)
+
+		require.NoError(t, s.Setup())
+
+		s.Cleanup()
+
+		assert.Equal(t, []string{
+			"init redis", "init cache",
+			"boot redis", "boot cache",
+			"cleanup cache", "cleanup redis",
+		}, logs)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		s := New().AddModulers(
+			depModule{name: "a", deps: []string{"b"}},
+			depModule{name: "b", deps: []string{"a"}},
+		)
+
+		assert.EqualError(t, s.Setup(), "dawn: module dependency cycle detected: a -> b -> a")
+	})
+
+	t.Run("run with error", func(t *testing.T) {
+		s := New(Config{App: fiber.New()}).AddModulers(
+			depModule{name: "a", deps: []string{"a"}},
+		)
+
+		assert.Error(t, s.Run(""))
+		assert.Error(t, s.RunTls("", "", ""))
+	})
+}
+
+type errModule struct {
+	depModule
+	initErr error
+	bootErr error
+}
+
+func (m errModule) InitE() (Cleanup, error) {
+	cleanup := m.depModule.Init()
+	return cleanup, m.initErr
+}
+
+func (m errModule) BootE() error {
+	*m.logs = append(*m.logs, "boot "+m.name)
+	return m.bootErr
+}
+
+type panicModule struct {
+	Module
+}
+
+func (panicModule) String() string { return "panic" }
+
+func (panicModule) Boot() { panic("boom") }
+
+func Test_Sloop_Setup_Error(t *testing.T) {
+	t.Parallel()
+
+	t.Run("init", func(t *testing.T) {
+		var logs []string
+
+		s := New().AddModulers(
+			depModule{name: "log", logs: &logs},
+			errModule{depModule: depModule{name: "sql", logs: &logs}, initErr: errors.New("no driver")},
+			depModule{name: "cache", deps: []string{"sql"}, logs: &logs},
+			errModule{depModule: depModule{name: "redis", logs: &logs}, initErr: errors.New("no addr")},
+		)
+
+		err := s.Setup()
+		require.Error(t, err)
+
+		var se SetupError
+		require.True(t, errors.As(err, &se))
+		require.Len(t, se, 3)
+		assert.Equal(t, "sql", se[0].Module)
+		assert.Equal(t, "init", se[0].Op)
+		assert.Equal(t, "no driver", errors.Unwrap(se[0]).Error())
+		assert.Equal(t, "dawn: failed to setup modules: "+
+			"sql init: no driver; cache init: dependency sql failed; redis init: no addr", err.Error())
+
+		// cleanups run in reverse order and only once
+		s.Cleanup()
+		assert.Equal(t, []string{
+			"init log", "init sql", "init redis",
+			"cleanup redis", "cleanup sql", "cleanup log",
+		}, logs)
+	})
+
+	t.Run("boot", func(t *testing.T) {
+		var logs []string
+
+		s := New().AddModulers(
+			errModule{depModule: depModule{name: "redis", logs: &logs}, bootErr: errors.New("ping")},
+			panicModule{},
+		)
+
+		assert.EqualError(t, s.Setup(), "dawn: failed to setup modules: "+
+			"redis boot: ping; panic boot: panic: boom")
+
+		assert.Equal(t, []string{
+			"init redis", "boot redis", "cleanup redis",
+		}, logs)
+	})
+}
+
+type stopModule struct {
+	Module
+	name  string
+	delay time.Duration
+	err   error
+}
+
+func (m stopModule) String() string { return m.name }
+
+func (m stopModule) Stop(ctx context.Context) error {
+	select {
+	case <-time.After(m.delay):
+		return m.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func Test_Sloop_CleanupContext(t *testing.T) {
+	t.Parallel()
+
+	var (
+		logs []string
+		buf  = new(bytes.Buffer)
+	)
+
+	s := New(Config{ErrorLog: log.New(buf, "", 0)}).AddModulers(
+		depModule{name: "first", logs: &logs},
+		stopModule{name: "slow", delay: time.Second},
+		stopModule{name: "fail", err: errors.New("oops")},
+		depModule{name: "log", logs: &logs},
+	)
+	require.NoError(t, s.Setup())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	s.CleanupContext(ctx)
+
+	// cleanups after the deadline are skipped instead of running concurrently
+	assert.Equal(t, []string{"init first", "init log", "boot first", "boot log", "cleanup log"}, logs)
+	assert.Equal(t, "dawn: failed to clean up fail: oops\n"+
+		"dawn: slow overran the shutdown deadline: context deadline exceeded\n"+
+		"dawn: skipped cleaning up first: context deadline exceeded\n", buf.String())
+	assert.Nil(t, s.cleanups)
+}
+
+func Test_Sloop_ShutdownWithContext(t *testing.T) {
+	t.Parallel()
+
+	require.NotNil(t, (&Sloop{}).ShutdownWithContext(context.Background()))
+
+	t.Run("drain timeout", func(t *testing.T) {
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		app.Get("/", func(c *fiber.Ctx) error {
+			time.Sleep(time.Millisecond * 500)
+			return nil
+		})
+
+		var logs []string
+		s := New(Config{App: app, ErrorLog: log.New(ioutil.Discard, "", 0)}).
+			AddModulers(depModule{name: "log", logs: &logs})
+		require.NoError(t, s.Setup())
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go func() { _ = app.Listener(ln) }()
+
+		go func() { _, _ = http.Get("http://" + ln.Addr().String()) }()
+		time.Sleep(time.Millisecond * 100)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+
+		assert.EqualError(t, s.ShutdownWithContext(ctx),
+			"dawn: failed to drain connections: context deadline exceeded")
+
+		// modules are still cleaned up with their own budget
+		assert.Equal(t, []string{"init log", "boot log", "cleanup log"}, logs)
+	})
+}
+
+func Test_Sloop_RunUntilSignal(t *testing.T) {
+	t.Parallel()
+
+	assert.NotNil(t, New().RunUntilSignal(""))
+
+	t.Run("setup error", func(t *testing.T) {
+		s := New(Config{App: fiber.New()}).AddModulers(
+			depModule{name: "a", deps: []string{"a"}},
+		)
+
+		assert.Error(t, s.RunUntilSignal(""))
+	})
+
+	t.Run("listen error", func(t *testing.T) {
+		s := New(Config{App: fiber.New()})
+
+		assert.Error(t, s.RunTlsUntilSignal("", "", ""))
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		var logs []string
+
+		s := New(Config{
+			App:             fiber.New(fiber.Config{DisableStartupMessage: true}),
+			ShutdownTimeout: time.Second,
+			ErrorLog:        log.New(ioutil.Discard, "", 0),
+		}).AddModulers(depModule{name: "log", logs: &logs})
+
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			s.sigCh <- syscall.SIGHUP
+			s.sigCh <- syscall.SIGTERM
+		}()
+
+		assert.NoError(t, s.RunUntilSignal("127.0.0.1:0"))
+		assert.Equal(t, []string{"init log", "boot log", "cleanup log"}, logs)
+	})
+
+	t.Run("listener", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		s := New(Config{
+			App:             fiber.New(fiber.Config{DisableStartupMessage: true}),
+			ShutdownTimeout: time.Second,
+			ErrorLog:        log.New(ioutil.Discard, "", 0),
+		})
+
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			s.sigCh <- syscall.SIGTERM
+		}()
+
+		assert.NoError(t, s.RunListenerUntilSignal(ln))
+	})
+}
+
+func Test_Sloop_shutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, DefaultShutdownTimeout, New().shutdownTimeout())
+	assert.Equal(t, time.Second, New(Config{ShutdownTimeout: time.Second}).shutdownTimeout())
+}
+
+func Test_Sloop_cleanupTimeout(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, DefaultCleanupTimeout, New().cleanupTimeout())
+	assert.Equal(t, time.Second, New(Config{CleanupTimeout: time.Second}).cleanupTimeout())
+}
+
+type reloaderModule struct {
+	Module
+	name  string
+	err   error
+	count *int32
+}
+
+func (m reloaderModule) String() string { return m.name }
+
+func (m reloaderModule) Reload() error {
+	atomic.AddInt32(m.count, 1)
+	return m.err
+}
+
+func Test_Sloop_Reload(t *testing.T) {
+	var (
+		count int32
+		buf   = new(bytes.Buffer)
+	)
+
+	s := New(Config{ErrorLog: log.New(buf, "", 0)}).AddModulers(
+		reloaderModule{name: "ok", count: &count},
+		reloaderModule{name: "bad", count: &count, err: errors.New("oops")},
+		m,
+	)
+	require.NoError(t, s.Setup())
+
+	require.NoError(t, s.Reload())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+	assert.Equal(t, "dawn: failed to reload: bad reload: oops\n", buf.String())
+
+	s.Cleanup()
+
+	// hook is unregistered after cleanup
+	require.NoError(t, s.Reload())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+}
+
+func Test_Sloop_Watch_Reload(t *testing.T) {
+	var count int32
+
+	s := New(Config{ErrorLog: log.New(ioutil.Discard, "", 0)}).
+		AddModulers(reloaderModule{name: "ok", count: &count})
+	require.NoError(t, s.Setup())
+	defer s.Cleanup()
+
+	done := make(chan struct{})
+	go func() {
+		s.Watch()
+		close(done)
+	}()
+
+	s.sigCh <- syscall.SIGHUP
+	s.sigCh <- syscall.SIGTERM
+
+	select {
+	case <-done:
+		assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	case <-time.After(time.Second):
+		assert.Fail(t, "should exit")
+	}
+}
+
+type checkModule struct {
+	Module
+	err error
+}
+
+func (checkModule) String() string { return "check" }
+
+func (m checkModule) Check(context.Context) error { return m.err }
+
+func Test_Sloop_HealthRoutes(t *testing.T) {
+	config.Set("health", map[string]interface{}{
+		"healthz": "/healthz",
+		"readyz":  "/readyz",
+		"livez":   "/livez",
+	})
+	defer config.Set("health", nil)
+
+	status := func(s *Sloop, target string) int {
+		resp, err := s.app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		s := New(Config{App: fiber.New()}).AddModulers(checkModule{})
+		s.registerRoutes()
+
+		assert.Equal(t, fiber.StatusOK, status(s, "/livez"))
+		assert.Equal(t, fiber.StatusOK, status(s, "/healthz"))
+		// not ready before setup
+		assert.Equal(t, fiber.StatusServiceUnavailable, status(s, "/readyz"))
+
+		require.NoError(t, s.Setup())
+		assert.Equal(t, fiber.StatusOK, status(s, "/readyz"))
+
+		// not ready after shutdown begins
+		_ = s.ShutdownWithContext(context.Background())
+		assert.Equal(t, fiber.StatusServiceUnavailable, status(s, "/readyz"))
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		s := New(Config{App: fiber.New()}).AddModulers(checkModule{err: errors.New("down")})
+		s.registerRoutes()
+		require.NoError(t, s.Setup())
+		defer s.Cleanup()
+
+		assert.Equal(t, fiber.StatusOK, status(s, "/livez"))
+		assert.Equal(t, fiber.StatusServiceUnavailable, status(s, "/healthz"))
+		assert.Equal(t, fiber.StatusServiceUnavailable, status(s, "/readyz"))
+	})
+}
+
+func Test_Sloop_HealthRoutes_Disabled(t *testing.T) {
+	s := New(Config{App: fiber.New()})
+	s.registerRoutes()
+
+	for _, target := range []string{"/healthz", "/readyz", "/livez"} {
+		resp, err := s.app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode, target)
+	}
+
+	config.Set("health.livez", "/live")
+	defer config.Set("health.livez", "")
+
+	s = New(Config{App: fiber.New()})
+	s.registerRoutes()
+
+	resp, err := s.app.Test(httptest.NewRequest(fiber.MethodGet, "/live", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = s.app.Test(httptest.NewRequest(fiber.MethodGet, "/healthz", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func Test_Sloop_DebugRoutes(t *testing.T) {
+	get := func(s *Sloop, target string) (*http.Response, string) {
+		resp, err := s.app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(b)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		s := New(Config{App: fiber.New()})
+		s.registerRoutes()
+
+		resp, _ := get(s, "/debug/config")
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("no path", func(t *testing.T) {
+		config.Set("debug", true)
+		defer config.Set("debug", false)
+
+		s := New(Config{App: fiber.New()})
+		s.registerRoutes()
+
+		resp, _ := get(s, "/debug/config")
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		require.NoError(t, os.Setenv("DAWN_TEST_DEBUG_PASS", "secret"))
+		defer func() { _ = os.Unsetenv("DAWN_TEST_DEBUG_PASS") }()
+
+		config.Set("debug", true)
+		config.Set("debugConfigPath", "/debug/config")
+		config.Set("debug_test.password", "${env:DAWN_TEST_DEBUG_PASS}")
+		config.Set("debug_test.sql.password", "plaintext")
+		defer config.Set("debug", false)
+		defer config.Set("debugConfigPath", "")
+
+		s := New(Config{App: fiber.New()})
+		s.registerRoutes()
+
+		resp, body := get(s, "/debug/config")
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get(fiber.HeaderContentType))
+		assert.Contains(t, body, `"password": "******"`)
+		assert.NotContains(t, body, "secret")
+		assert.NotContains(t, body, "plaintext")
+
+		resp, body = get(s, "/debug/config?format=toml")
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Contains(t, body, `password = "******"`)
+
+		resp, _ = get(s, "/debug/config?format=xml")
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
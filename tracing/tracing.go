@@ -0,0 +1,401 @@
+// Package tracing traces requests, GORM queries and go-redis commands
+// with OpenTelemetry. The span of a request is carried by
+// fiberx.UserContext(c) rather than c.UserContext(), which isn't provided
+// by the fiber version in use, so handlers must use the former to get
+// child spans. The latter won't see the span even after fiber is upgraded
+// until Middleware switches to it.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-dawn/dawn"
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/db/redis"
+	"github.com/go-dawn/dawn/db/sql"
+	"github.com/go-dawn/dawn/fiberx"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagation"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	instrumentationName = "github.com/go-dawn/dawn/tracing"
+	unmatched           = "unmatched"
+	spanKey             = "dawn:tracing:span"
+)
+
+var (
+	m        = &Module{}
+	hookOnce sync.Once
+)
+
+type Module struct {
+	dawn.Module
+	provider *sdktrace.TracerProvider
+	closer   io.Closer
+}
+
+// New gets the moduler
+func New() *Module {
+	return m
+}
+
+// String is module name
+func (*Module) String() string {
+	return "dawn:tracing"
+}
+
+// Init panics if InitE fails
+func (m *Module) Init() dawn.Cleanup {
+	if _, err := m.InitE(); err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+// InitE sets up the global tracer provider by config:
+//  [Tracing]
+//  ServiceName = "dawn"
+//  # stdout, file or otlp
+//  Exporter = "stdout"
+//  # used by file exporter
+//  File = "traces.json"
+//  # used by otlp exporter
+//  Protocol = "grpc" # or http
+//  Endpoint = "localhost:4317"
+//  Insecure = false
+//  # sample ratio of root spans, between 0 and 1
+//  SampleRatio = 1.0
+//  [Tracing.Headers]
+//  key = "value"
+// W3C trace context and baggage are used as propagators.
+func (m *Module) InitE() (dawn.Cleanup, error) {
+	c := config.Sub("tracing")
+
+	exporter, err := m.exporter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	m.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler: sdktrace.ParentBased(
+				sdktrace.TraceIDRatioBased(c.GetFloat64("sampleRatio", 1)),
+			),
+		}),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.ServiceNameKey.String(c.GetString("serviceName", "dawn")),
+		)),
+	)
+
+	otel.SetTracerProvider(m.provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	return nil, nil
+}
+
+func (m *Module) exporter(c *config.Config) (export.SpanExporter, error) {
+	switch typ := strings.ToLower(c.GetString("exporter", "stdout")); typ {
+	case "stdout":
+		return stdout.NewExporter(stdout.WithoutMetricExport())
+	case "file":
+		f, err := os.OpenFile(c.GetString("file", "traces.json"),
+			os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("dawn:tracing failed to open file: %w", err)
+		}
+		m.closer = f
+		return stdout.NewExporter(stdout.WithWriter(f), stdout.WithoutMetricExport())
+	case "otlp":
+		return otlp.NewExporter(context.Background(), otlpDriver(c))
+	default:
+		return nil, fmt.Errorf("dawn:tracing unknown exporter %s", typ)
+	}
+}
+
+func otlpDriver(c *config.Config) otlp.ProtocolDriver {
+	endpoint := c.GetString("endpoint")
+	headers := c.GetStringMapString("headers")
+
+	if strings.ToLower(c.GetString("protocol", "grpc")) == "http" {
+		opts := []otlphttp.Option{otlphttp.WithHeaders(headers)}
+		if endpoint != "" {
+			opts = append(opts, otlphttp.WithEndpoint(endpoint))
+		}
+		if c.GetBool("insecure") {
+			opts = append(opts, otlphttp.WithInsecure())
+		}
+		return otlphttp.NewDriver(opts...)
+	}
+
+	opts := []otlpgrpc.Option{otlpgrpc.WithHeaders(headers)}
+	if endpoint != "" {
+		opts = append(opts, otlpgrpc.WithEndpoint(endpoint))
+	}
+	if c.GetBool("insecure") {
+		opts = append(opts, otlpgrpc.WithInsecure())
+	}
+	return otlpgrpc.NewDriver(opts...)
+}
+
+// BootE instruments connections of dawn:sql and dawn:redis
+func (m *Module) BootE() (err error) {
+	hookOnce.Do(func() {
+		redis.OnConnect(instrumentRedis)
+		err = sql.OnConnect(instrumentSql)
+	})
+
+	return
+}
+
+// Stop flushes pending spans and shuts down the tracer provider
+func (m *Module) Stop(ctx context.Context) (err error) {
+	if m.provider != nil {
+		err = m.provider.Shutdown(ctx)
+		m.provider = nil
+	}
+
+	if m.closer != nil {
+		if e := m.closer.Close(); err == nil {
+			err = e
+		}
+		m.closer = nil
+	}
+
+	return
+}
+
+// Tracer returns a named tracer from the global tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Middleware starts a server span for every request, which continues
+// the trace of the incoming traceparent header if present. The span
+// context is propagated into fiberx.UserContext(c), and should be
+// passed on to queries and commands to make them child spans, e.g.
+//  sql.Conn().WithContext(fiberx.UserContext(c))
+//  redis.Conn().Get(fiberx.UserContext(c), key)
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		self := c.Route()
+
+		ctx := otel.GetTextMapPropagator().Extract(fiberx.UserContext(c),
+			headerCarrier{&c.Request().Header})
+
+		ctx, span := Tracer().Start(ctx, "HTTP "+c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(c.Method()),
+				semconv.HTTPTargetKey.String(string(c.Request().RequestURI())),
+				semconv.HTTPSchemeKey.String(c.Protocol()),
+				semconv.HTTPServerNameKey.String(c.Hostname()),
+				semconv.HTTPClientIPKey.String(c.IP()),
+				semconv.HTTPUserAgentKey.String(string(c.Request().Header.UserAgent())),
+			),
+		)
+		defer span.End()
+
+		// set by requestid middleware
+		if id := c.Response().Header.Peek(fiber.HeaderXRequestID); len(id) > 0 {
+			span.SetAttributes(attribute.String("http.request_id", string(id)))
+		}
+
+		fiberx.SetUserContext(c, ctx)
+
+		err = c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+			span.RecordError(err)
+		}
+
+		path := c.Route().Path
+		// no route matched after this middleware
+		if c.Route() == self && status == fiber.StatusNotFound {
+			path = unmatched
+		}
+
+		span.SetName(c.Method() + " " + path)
+		span.SetAttributes(semconv.HTTPRouteKey.String(path))
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+
+		return
+	}
+}
+
+// headerCarrier adapts fasthttp request header to TextMapCarrier
+type headerCarrier struct {
+	h *fasthttp.RequestHeader
+}
+
+func (hc headerCarrier) Get(key string) string {
+	return string(hc.h.Peek(key))
+}
+
+func (hc headerCarrier) Set(key, value string) {
+	hc.h.Set(key, value)
+}
+
+func (hc headerCarrier) Keys() []string {
+	keys := make([]string, 0, hc.h.Len())
+	hc.h.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
+
+// instrumentSql registers gorm callbacks to start a child span
+// of the statement context for every operation
+func instrumentSql(name string, db *gorm.DB) error {
+	system := db.Dialector.Name()
+
+	before := func(op string) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			ctx := db.Statement.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			ctx, span := Tracer().Start(ctx, "gorm."+op,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemKey.String(system),
+					semconv.DBNameKey.String(name),
+					semconv.DBOperationKey.String(op),
+				),
+			)
+
+			db.Statement.Context = ctx
+			db.InstanceSet(spanKey, span)
+		}
+	}
+
+	after := func(db *gorm.DB) {
+		v, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		defer span.End()
+
+		span.SetAttributes(
+			semconv.DBStatementKey.String(db.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+		)
+
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			span.RecordError(db.Error)
+			span.SetStatus(codes.Error, db.Error.Error())
+		}
+	}
+
+	cb := db.Callback()
+
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("dawn:tracing:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("dawn:tracing:after_create", after),
+		cb.Query().Before("gorm:query").Register("dawn:tracing:before_query", before("query")),
+		cb.Query().After("gorm:query").Register("dawn:tracing:after_query", after),
+		cb.Update().Before("gorm:update").Register("dawn:tracing:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("dawn:tracing:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("dawn:tracing:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("dawn:tracing:after_delete", after),
+		cb.Row().Before("gorm:row").Register("dawn:tracing:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("dawn:tracing:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("dawn:tracing:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("dawn:tracing:after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// instrumentRedis adds a hook to start a child span for every command
+func instrumentRedis(name string, client *goredis.Client) {
+	client.AddHook(redisHook{name})
+}
+
+type redisHook struct {
+	name string
+}
+
+func (h redisHook) BeforeProcess(ctx context.Context, cmd goredis.Cmder) (context.Context, error) {
+	return h.start(ctx, cmd.Name(), 1), nil
+}
+
+func (h redisHook) AfterProcess(ctx context.Context, cmd goredis.Cmder) error {
+	h.end(ctx, cmd.Err())
+	return nil
+}
+
+func (h redisHook) BeforeProcessPipeline(ctx context.Context, cmds []goredis.Cmder) (context.Context, error) {
+	return h.start(ctx, "pipeline", len(cmds)), nil
+}
+
+func (h redisHook) AfterProcessPipeline(ctx context.Context, cmds []goredis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if e := cmd.Err(); e != nil && e != goredis.Nil {
+			err = e
+			break
+		}
+	}
+	h.end(ctx, err)
+	return nil
+}
+
+func (h redisHook) start(ctx context.Context, command string, n int) context.Context {
+	ctx, _ = Tracer().Start(ctx, "redis."+command,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String("redis"),
+			semconv.DBNameKey.String(h.name),
+			semconv.DBOperationKey.String(command),
+			attribute.Int("db.redis.num_cmd", n),
+		),
+	)
+	return ctx
+}
+
+func (h redisHook) end(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	if err != nil && err != goredis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/db/sql"
+	"github.com/go-dawn/dawn/fiberx"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+)
+
+func Test_Tracing_Module_Name(t *testing.T) {
+	assert.Equal(t, "dawn:tracing", New().String())
+}
+
+func Test_Tracing_Module(t *testing.T) {
+	file := filepath.Join(tempDir(t), "traces.json")
+	config.Set("tracing", map[string]interface{}{
+		"exporter": "file",
+		"file":     file,
+	})
+	defer config.Set("tracing", nil)
+
+	m := New()
+	_, err := m.InitE()
+	require.NoError(t, err)
+	require.NoError(t, m.BootE())
+
+	if fi, err := os.Stat(file); assert.NoError(t, err) && os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	// sql
+	cleanup, err := sql.New().InitE()
+	require.NoError(t, err)
+	defer cleanup()
+
+	// redis
+	client := goredis.NewClient(&goredis.Options{Addr: "127.0.0.1:99999", MaxRetries: -1})
+	instrumentRedis("default", client)
+
+	app := fiber.New()
+	app.Use(requestid.New(), Middleware())
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		ctx := fiberx.UserContext(c)
+		if err := sql.Conn().WithContext(ctx).Exec("SELECT 1").Error; err != nil {
+			return err
+		}
+		_ = client.Get(ctx, "key").Err()
+		_, _ = client.Pipelined(ctx, func(p goredis.Pipeliner) error {
+			p.Get(ctx, "key")
+			return nil
+		})
+		return c.SendString("user")
+	})
+	app.Get("/error", func(c *fiber.Ctx) error {
+		return fiber.ErrBadRequest
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", traceparent)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	for _, target := range []string{"/error", "/non"} {
+		_, err = app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, m.Stop(context.Background()))
+
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	body := string(b)
+
+	for _, s := range []string{
+		traceID,
+		`"Name":"GET /users/:id"`,
+		`"Name":"GET /error"`,
+		`"Name":"GET unmatched"`,
+		`"Name":"gorm.raw"`,
+		`"Name":"redis.get"`,
+		`"Name":"redis.pipeline"`,
+		`"Key":"http.request_id"`,
+		`"Value":"SELECT 1"`,
+		`"Value":"dawn"`,
+	} {
+		assert.Contains(t, body, s)
+	}
+}
+
+func Test_Tracing_Module_Exporter(t *testing.T) {
+	defer config.Set("tracing", nil)
+
+	m := &Module{}
+
+	config.Set("tracing", map[string]interface{}{"exporter": "unknown"})
+	_, err := m.InitE()
+	assert.EqualError(t, err, "dawn:tracing unknown exporter unknown")
+
+	config.Set("tracing", map[string]interface{}{
+		"exporter": "file",
+		"file":     filepath.Join(tempDir(t), "non", "traces.json"),
+	})
+	_, err = m.InitE()
+	assert.Contains(t, err.Error(), "dawn:tracing failed to open file")
+	assert.Panics(t, func() { m.Init() })
+
+	for _, protocol := range []string{"grpc", "http"} {
+		config.Set("tracing", map[string]interface{}{
+			"exporter": "otlp",
+			"protocol": protocol,
+			"endpoint": "127.0.0.1:99999",
+			"insecure": true,
+		})
+		_, err = m.InitE()
+		require.NoError(t, err)
+		require.NoError(t, m.Stop(context.Background()))
+	}
+}
+
+func Test_Tracing_Middleware_Without_Init(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/", func(c *fiber.Ctx) error {
+		assert.NotNil(t, fiberx.UserContext(c))
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+// tempDir works like t.TempDir which is not available before go1.15
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dawn")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}